@@ -2,49 +2,476 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/Mr-Cheen1/go-reg-wails/backend/config"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/history"
 	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
 	"github.com/Mr-Cheen1/go-reg-wails/backend/storage"
 	"github.com/Mr-Cheen1/go-reg-wails/backend/utils"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// historyDepth количество изменений, которые можно отменить
+const historyDepth = 100
+
+// watchInterval период проверки файла базы данных на изменения другими программами
+const watchInterval = 2 * time.Second
+
+// EventProductsReloaded событие фронтенда: данные перечитаны из файла,
+// измененного другой программой, таблицу нужно обновить
+const EventProductsReloaded = "products:reloaded"
+
+// EventProductsChanged событие фронтенда: данные изменены через HTTP API,
+// таблицу нужно обновить
+const EventProductsChanged = "products:changed"
+
+// EventLockLost событие фронтенда: блокировку базы данных забрала другая
+// копия приложения, изменения запрещены; данные события описание владельца
+const EventLockLost = "database:lock-lost"
+
 // App структура приложения
 type App struct {
 	ctx      context.Context
 	storage  storage.Storage
-	products models.Products
+	products *models.Repository
+	// constants, storage и history используются только под блокировкой
+	// записи products, поэтому изменения продуктов и констант не пересекаются
+	constants models.Constants
+	history   *history.History
+	// mismatches продукты, время которых при загрузке не совпало с формулой;
+	// используется под той же блокировкой
+	mismatches []TimeMismatch
+	// readOnly режим просмотра, включенный настройкой или флагом запуска
+	readOnly bool
+	// lockErr причина, по которой изменения запрещены: базу данных
+	// удерживает другая копия приложения
+	lockErr error
+	// loadErr ошибка последней загрузки базы данных: пока база не перечитана,
+	// изменения запрещены, иначе сохранение записало бы данные поверх файла,
+	// который не удалось прочитать
+	loadErr error
+	// settings настройки приложения и файл, в котором они хранятся;
+	// пустой settingsPath означает, что настройки не сохраняются
+	settings     config.Settings
+	settingsPath string
+	// settingsErr ошибка чтения настроек при запуске, о которой нужно сообщить пользователю
+	settingsErr error
+	// newStorage создает хранилище для открываемой базы данных, в тестах подменяется
+	newStorage func(filename string) storage.Storage
+	// api HTTP API, запущенный по настройке, или nil
+	api *APIServer
+	// emit отправляет событие фронтенду, в тестах подменяется
+	emit         func(ctx context.Context, name string, data ...interface{})
+	stopWatching context.CancelFunc
+}
+
+// databaseFilters типы файлов, которые можно открыть как базу данных
+var databaseFilters = []runtime.FileFilter{
+	{DisplayName: "Базы данных (*.xlsx, *.db, *.sqlite)", Pattern: "*.xlsx;*.db;*.sqlite;*.sqlite3"},
+	{DisplayName: "Excel (*.xlsx)", Pattern: "*.xlsx"},
+	{DisplayName: "SQLite (*.db, *.sqlite)", Pattern: "*.db;*.sqlite;*.sqlite3"},
+}
+
+// ErrReadOnly возвращается при попытке изменить данные в режиме только для чтения
+var ErrReadOnly = errors.New("база данных открыта только для чтения")
+
+// ErrInvalidFormula возвращается, если формулу расчета времени нельзя вычислить
+var ErrInvalidFormula = errors.New("некорректная формула расчета времени")
+
+// ErrReferenced возвращается при удалении продукта, на который ссылаются формулы других продуктов
+var ErrReferenced = errors.New("на продукт ссылаются другие продукты")
+
+// ReadOnlyError возвращается при попытке изменить данные, когда изменения запрещены.
+// Проверяется через errors.Is(err, ErrReadOnly).
+type ReadOnlyError struct {
+	// Reason причина запрета: блокировка базы другой копией приложения,
+	// ошибка загрузки базы или nil, если включен режим просмотра
+	Reason error
+}
+
+// Error возвращает описание запрета
+func (e *ReadOnlyError) Error() string {
+	if e.Reason == nil {
+		return ErrReadOnly.Error() + ": включен режим просмотра"
+	}
+	return ErrReadOnly.Error() + ": " + e.Reason.Error()
+}
+
+// Unwrap позволяет проверять и сам запрет, и его причину
+func (e *ReadOnlyError) Unwrap() []error {
+	return []error{ErrReadOnly, e.Reason}
+}
+
+// ErrorCodeConflict код ошибки AppError: продукт после получения пользователем
+// изменили или удалили другие
+const ErrorCodeConflict = "conflict"
+
+// AppError ошибка метода приложения, которую фронтенд распознает по коду,
+// а не по тексту сообщения
+type AppError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Current текущее состояние продукта при конфликте или nil, если продукт удален
+	Current *models.Product `json:"current"`
+}
+
+// formatError передает фронтенду конфликт версий в виде AppError,
+// а остальные ошибки текстом, как без форматирования
+func formatError(err error) any {
+	var conflict *models.ConflictError
+	if errors.As(err, &conflict) {
+		return AppError{Code: ErrorCodeConflict, Message: err.Error(), Current: conflict.Current}
+	}
+	return err.Error()
 }
 
 // NewApp создает новый экземпляр приложения
-func NewApp(storage storage.Storage) *App {
-	return &App{
-		storage: storage,
+func NewApp(dataStorage storage.Storage) *App {
+	a := &App{
+		storage:  dataStorage,
+		products: models.NewRepository(nil),
+		history:  history.New(historyDepth),
+		settings: config.Default(),
+		emit:     runtime.EventsEmit,
+	}
+	a.newStorage = func(filename string) storage.Storage {
+		return storage.NewForFile(filename, a.readOnly, excelOptions(a.settings))
 	}
+	return a
+}
+
+// excelOptions возвращает настройки Excel файлов из настроек приложения
+func excelOptions(settings config.Settings) storage.ExcelOptions {
+	return storage.ExcelOptions{
+		Sheet:         settings.Excel.Sheet,
+		ColumnAliases: storage.ColumnAliases(settings.Excel.ColumnAliases),
+		Backups: storage.BackupPolicy{
+			KeepLast: settings.Backup.KeepLast,
+			KeepDays: settings.Backup.KeepDays,
+		},
+	}
+}
+
+// WithSettings задает настройки приложения и файл, в который сохраняются их изменения
+func (a *App) WithSettings(filename string, settings config.Settings) *App {
+	a.settingsPath = filename
+	a.settings = settings
+	return a
+}
+
+// WithSettingsError запоминает ошибку чтения настроек при запуске,
+// чтобы фронтенд сообщил пользователю, что настройки сброшены
+func (a *App) WithSettingsError(err error) *App {
+	a.settingsErr = err
+	return a
+}
+
+// WithReadOnly включает режим просмотра: любые изменения данных отклоняются
+func (a *App) WithReadOnly(readOnly bool) *App {
+	a.readOnly = readOnly
+	return a
+}
+
+// IsReadOnly сообщает, запрещены ли изменения данных,
+// чтобы фронтенд скрыл элементы редактирования
+func (a *App) IsReadOnly() bool {
+	var readOnly bool
+	a.products.Read(func(models.Products) {
+		readOnly = a.writable() != nil
+	})
+	return readOnly
 }
 
 // Startup вызывается при запуске приложения
 func (a *App) Startup(ctx context.Context) {
 	a.ctx = ctx
-	
-	var err error
-	a.products, err = a.storage.Load()
+
+	// Загрузка при запуске проходит так же, как при перечитывании файла:
+	// время обработки проверяется по формулам. Если базу не удалось загрузить,
+	// изменения запрещены, пока она не будет перечитана или заменена другой
+	a.products.Write(func(products *models.Products) error {
+		if err := a.reload(products); err != nil {
+			log.Printf("Данные не загружены: %v\n", err)
+			a.checkLock()
+		}
+		return nil
+	})
+
+	// Наблюдение запускается всегда: база может смениться на отслеживаемую
+	watchCtx, cancel := context.WithCancel(ctx)
+	a.stopWatching = cancel
+	go a.watch(watchCtx, watchInterval)
+	if err := a.startAPI(); err != nil {
+		log.Printf("HTTP API не запущен: %v\n", err)
+	}
+}
+
+// startAPI запускает HTTP API, если он включен в настройках.
+// Если ключ доступа не задан, он создается и сохраняется в настройках.
+func (a *App) startAPI() error {
+	var settings config.API
+	err := a.products.Write(func(*models.Products) error {
+		if a.settings.API.Enabled && a.settings.API.Token == "" {
+			token, err := newAPIToken()
+			if err != nil {
+				return err
+			}
+			a.settings.API.Token = token
+			if err := a.saveSettings(); err != nil {
+				return fmt.Errorf("ошибка сохранения ключа доступа: %w", err)
+			}
+		}
+		settings = a.settings.API
+		return nil
+	})
+	if err != nil || !settings.Enabled {
+		return err
+	}
+
+	api := NewAPIServer(a, settings.Token)
+	if err := api.Start(settings.Address); err != nil {
+		return err
+	}
+	a.api = api
+	return nil
+}
+
+// Shutdown вызывается при закрытии приложения
+func (a *App) Shutdown(ctx context.Context) {
+	if a.stopWatching != nil {
+		a.stopWatching()
+	}
+	if a.api != nil {
+		if err := a.api.Shutdown(ctx); err != nil {
+			log.Printf("Ошибка остановки HTTP API: %v\n", err)
+		}
+	}
+	// Хранилище закрывает приложение: открытая база могла смениться после запуска
+	a.products.Write(func(*models.Products) error {
+		if err := a.storage.Close(); err != nil {
+			log.Printf("Ошибка закрытия базы данных: %v\n", err)
+		}
+		return nil
+	})
+}
+
+// watch периодически проверяет, не изменила ли файл базы данных другая программа
+func (a *App) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.refreshLock()
+			if _, err := a.reloadIfChanged(); err != nil {
+				log.Printf("Ошибка проверки изменений файла: %v\n", err)
+			}
+		}
+	}
+}
+
+// reloadIfChanged перечитывает продукты и константы, если файл базы данных
+// изменен другой программой, и сообщает фронтенду об обновлении
+func (a *App) reloadIfChanged() (bool, error) {
+	var changed bool
+	err := a.products.Write(func(products *models.Products) error {
+		watched, ok := a.storage.(storage.WatchedStorage)
+		if !ok {
+			return nil
+		}
+		var err error
+		changed, err = watched.Changed()
+		if err != nil || !changed {
+			return err
+		}
+		// История относится к данным до изменения файла
+		a.history.Clear()
+		return a.reload(products)
+	})
 	if err != nil {
-		log.Printf("Ошибка загрузки данных: %v\n", err)
+		return false, err
 	}
+	if changed {
+		a.emit(a.ctx, EventProductsReloaded)
+	}
+	return changed, nil
+}
+
+// mutate выполняет изменение под блокировкой списка продуктов. Если изменение
+// не удалось сохранить, список и константы возвращаются к состоянию до него,
+// чтобы в памяти не осталось несохраненных данных. Если сохранение отклонено
+// из-за изменения файла другой программой, данные перечитываются из файла,
+// а фронтенд получает событие об обновлении.
+func (a *App) mutate(fn func(products *models.Products) error) error {
+	err := a.products.Write(func(products *models.Products) error {
+		if err := a.writable(); err != nil {
+			return err
+		}
+		// Записи истории ссылаются на список, поэтому fn изменяет сам список,
+		// а при ошибке восстанавливается копия
+		snapshot := slices.Clone(*products)
+		constants, mismatches := slices.Clone(a.constants), slices.Clone(a.mismatches)
+		err := fn(products)
+		var conflict *models.ConflictError
+		if err == nil || errors.As(err, &conflict) {
+			// Конфликт версий обнаруживается до изменения, а список уже
+			// дополнен данными из хранилища, которые нужно оставить
+			return err
+		}
+		if !errors.Is(err, storage.ErrConflict) {
+			*products, a.constants, a.mismatches = snapshot, constants, mismatches
+			return err
+		}
+		a.history.Clear()
+		if reloadErr := a.reload(products); reloadErr != nil {
+			return errors.Join(err, reloadErr)
+		}
+		return fmt.Errorf("%w: данные перечитаны из файла, повторите изменение", err)
+	})
+	if errors.Is(err, storage.ErrConflict) {
+		a.emit(a.ctx, EventProductsReloaded)
+	}
+	return err
 }
 
 // OnDomReady вызывается когда DOM готов
 func (a *App) OnDomReady(ctx context.Context) {
-	// Принудительно устанавливаем размер окна когда DOM готов
-	runtime.WindowSetSize(ctx, 800, 650)
-	runtime.WindowCenter(ctx)
+	window := a.GetSettings().Window
+
+	// Восстанавливаем размер и положение окна при последнем закрытии
+	runtime.WindowSetSize(ctx, window.Width, window.Height)
+	if window.X == 0 && window.Y == 0 {
+		runtime.WindowCenter(ctx)
+	} else {
+		runtime.WindowSetPosition(ctx, window.X, window.Y)
+	}
+	if window.Maximised {
+		runtime.WindowMaximise(ctx)
+	}
+}
+
+// BeforeClose вызывается перед закрытием окна и запоминает его размер и положение
+func (a *App) BeforeClose(ctx context.Context) bool {
+	// Свернутое окно не имеет осмысленного положения
+	if runtime.WindowIsMinimised(ctx) {
+		return false
+	}
+
+	width, height := runtime.WindowGetSize(ctx)
+	x, y := runtime.WindowGetPosition(ctx)
+	a.rememberWindow(config.Window{
+		Width: width, Height: height, X: x, Y: y,
+		Maximised: runtime.WindowIsMaximised(ctx),
+	})
+	return false
+}
+
+// rememberWindow сохраняет размер и положение окна в настройках.
+// У развернутого окна запоминается только признак развернутости,
+// чтобы после восстановления окно вернулось к прежнему размеру.
+func (a *App) rememberWindow(window config.Window) {
+	a.products.Write(func(*models.Products) error {
+		if window.Maximised {
+			a.settings.Window.Maximised = true
+		} else {
+			a.settings.Window = window
+		}
+		a.settings.Normalize()
+		if err := a.saveSettings(); err != nil {
+			log.Printf("Ошибка сохранения настроек: %v\n", err)
+		}
+		return nil
+	})
 }
 
-// GetProducts возвращает все продукты
+// GetSettings возвращает копию настроек приложения
+func (a *App) GetSettings() config.Settings {
+	var settings config.Settings
+	a.products.Read(func(models.Products) {
+		settings = a.settings
+		settings.RecentDatabases = slices.Clone(a.settings.RecentDatabases)
+		settings.Excel.ColumnAliases = maps.Clone(a.settings.Excel.ColumnAliases)
+	})
+	return settings
+}
+
+// GetSettingsError возвращает описание ошибки чтения настроек при запуске
+// или пустую строку, если настройки прочитаны
+func (a *App) GetSettingsError() string {
+	if a.settingsErr == nil {
+		return ""
+	}
+	return a.settingsErr.Error()
+}
+
+// UpdateSettings изменяет и сохраняет настройки отображения и режим просмотра.
+// Режим просмотра применяется при следующем запуске. Путь к базе и список недавних
+// баз меняются только при открытии баз, размер окна запоминается при закрытии.
+func (a *App) UpdateSettings(settings config.Settings) error {
+	return a.products.Write(func(*models.Products) error {
+		updated := a.settings
+		updated.ReadOnly = settings.ReadOnly
+		updated.View = settings.View
+		updated.Normalize()
+
+		previous := a.settings
+		a.settings = updated
+		if err := a.saveSettings(); err != nil {
+			a.settings = previous
+			return err
+		}
+		return nil
+	})
+}
+
+// SetShiftHours изменяет длительность смены, сохраняет ее в настройках
+// и пересчитывает время обработки продуктов по формулам
+func (a *App) SetShiftHours(hours float64) error {
+	if err := utils.ValidateShiftHours(hours); err != nil {
+		return err
+	}
+	return a.mutate(func(products *models.Products) error {
+		settings := a.settings
+		a.settings.ShiftHours = hours
+		if err := a.saveSettings(); err != nil {
+			a.settings = settings
+			return err
+		}
+
+		var ids []int
+		for _, product := range *products {
+			if strings.TrimSpace(product.TimeCalculation) != "" {
+				ids = append(ids, product.ID)
+			}
+		}
+		changed := a.recalculate(*products, ids)
+		if len(changed) == 0 {
+			return nil
+		}
+		if err := a.persistUpdate(*products, changed, nil); err != nil {
+			// Время продуктов должно соответствовать сохраненной длительности смены
+			a.settings = settings
+			return errors.Join(err, a.saveSettings())
+		}
+		return nil
+	})
+}
+
+// GetProducts возвращает копию всех продуктов
 func (a *App) GetProducts() []models.Product {
-	return a.products
+	return a.products.All()
 }
 
 // SearchProducts ищет продукты по запросу
@@ -52,40 +479,866 @@ func (a *App) SearchProducts(query string) []models.Product {
 	return a.products.Search(query)
 }
 
+// FormulaValidation результат проверки формулы расчета времени
+type FormulaValidation struct {
+	Valid          bool                `json:"valid"`
+	ProcessingTime float64             `json:"processingTime"`
+	Error          *utils.FormulaError `json:"error,omitempty"`
+}
+
+// TimeMismatch продукт, у которого время обработки в базе данных
+// не совпадало со временем, рассчитанным по его формуле, или формулу
+// не удалось вычислить
+type TimeMismatch struct {
+	ID              int     `json:"id"`
+	Name            string  `json:"name"`
+	TimeCalculation string  `json:"timeCalculation"`
+	StoredTime      float64 `json:"storedTime"`
+	CalculatedTime  float64 `json:"calculatedTime"`
+	// Error ошибка вычисления формулы; время такого продукта остается прежним
+	Error string `json:"error,omitempty"`
+}
+
+// ValidateFormula проверяет формулу и возвращает рассчитанное время
+// или описание ошибки для отображения в диалогах
+func (a *App) ValidateFormula(expr string) FormulaValidation {
+	var processingTime float64
+	var err error
+	a.products.Read(func(products models.Products) {
+		processingTime, err = a.resolver(products).Calculate(expr)
+	})
+	if err != nil {
+		var formulaErr *utils.FormulaError
+		if !errors.As(err, &formulaErr) {
+			formulaErr = &utils.FormulaError{Message: err.Error()}
+		}
+		return FormulaValidation{Error: formulaErr}
+	}
+	return FormulaValidation{Valid: true, ProcessingTime: processingTime}
+}
+
+// calculateTime вычисляет время обработки продукта с учетом констант и ссылок
+// на другие продукты, отклоняя некорректные и циклические формулы
+func (a *App) calculateTime(products models.Products, id int, timeCalculation string) (float64, error) {
+	candidates := make(models.Products, 0, len(products)+1)
+	for _, product := range products {
+		if product.ID != id {
+			candidates = append(candidates, product)
+		}
+	}
+	candidates = append(candidates, models.Product{ID: id, TimeCalculation: timeCalculation})
+
+	processingTime, err := a.resolver(candidates).ProductTime(id)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidFormula, err)
+	}
+	return processingTime, nil
+}
+
 // AddProduct добавляет новый продукт
 func (a *App) AddProduct(name, timeCalculation string) error {
-	processingTime := utils.CalculateTime(timeCalculation)
-	product := models.Product{
-		ID:              a.products.GetNextID(),
-		Name:            name,
-		ProcessingTime:  processingTime,
-		TimeCalculation: timeCalculation,
-	}
-	a.products = append(a.products, product)
-	return a.storage.Save(a.products)
+	_, err := a.addProduct(name, timeCalculation)
+	return err
+}
+
+// addProduct добавляет продукт и возвращает его вместе с присвоенным ID
+func (a *App) addProduct(name, timeCalculation string) (models.Product, error) {
+	var product models.Product
+	err := a.mutate(func(products *models.Products) error {
+		var err error
+		product, err = a.insertNewProduct(products, name, timeCalculation)
+		return err
+	})
+	return product, err
 }
 
-// UpdateProduct обновляет существующий продукт
-func (a *App) UpdateProduct(id int, name, timeCalculation string) error {
-	processingTime := utils.CalculateTime(timeCalculation)
+// insertNewProduct добавляет продукт в конец списка и записывает добавление в историю
+func (a *App) insertNewProduct(products *models.Products, name, timeCalculation string) (models.Product, error) {
+	id := products.GetNextID()
+	processingTime, err := a.calculateTime(*products, id, timeCalculation)
+	if err != nil {
+		return models.Product{}, err
+	}
 	product := models.Product{
 		ID:              id,
 		Name:            name,
 		ProcessingTime:  processingTime,
 		TimeCalculation: timeCalculation,
+		Version:         1,
+		UpdatedAt:       currentTime(),
+	}
+	added := []positionedProduct{{index: len(*products), product: product}}
+	if err := a.insertProducts(products, added); err != nil {
+		return models.Product{}, err
 	}
-	a.products.Update(product)
-	return a.storage.Save(a.products)
+
+	a.history.Push(history.Entry{
+		Label: fmt.Sprintf("добавление %q", name),
+		Redo:  func() error { return a.insertProducts(products, added) },
+		Undo:  func() error { return a.removeProducts(products, []int{id}) },
+	})
+	return product, nil
+}
+
+// UpdateProduct обновляет существующий продукт и пересчитывает время продуктов,
+// ссылающихся на него. version - версия продукта, которую видел пользователь:
+// если продукт с тех пор изменили, возвращается *models.ConflictError.
+func (a *App) UpdateProduct(id, version int, name, timeCalculation string) error {
+	return a.mutate(func(products *models.Products) error {
+		if err := a.checkVersion(products, id, version); err != nil {
+			return err
+		}
+		previous := (*products)[products.IndexOf(id)]
+
+		processingTime, err := a.calculateTime(*products, id, timeCalculation)
+		if err != nil {
+			return err
+		}
+		product := models.Product{
+			ID:              id,
+			Name:            name,
+			ProcessingTime:  processingTime,
+			TimeCalculation: timeCalculation,
+		}
+		if err := a.editProduct(products, product); err != nil {
+			return err
+		}
+
+		a.history.Push(history.Entry{
+			Label: fmt.Sprintf("изменение %q", previous.Name),
+			Redo:  func() error { return a.editProduct(products, product) },
+			Undo:  func() error { return a.editProduct(products, previous) },
+		})
+		return nil
+	})
+}
+
+// DeleteProduct удаляет продукт по ID. Продукт, на который ссылаются формулы
+// других продуктов, удалить нельзя. version - версия продукта, которую видел
+// пользователь: если продукт с тех пор изменили, возвращается *models.ConflictError.
+func (a *App) DeleteProduct(id, version int) error {
+	return a.mutate(func(products *models.Products) error {
+		if err := a.checkVersion(products, id, version); err != nil {
+			return err
+		}
+		return a.deleteProducts(products, []int{id})
+	})
+}
+
+// DeleteProducts удаляет несколько продуктов. versions - версии продуктов
+// по ID, которые видел пользователь: если какой-либо из продуктов с тех пор
+// изменили или удалили, ничего не удаляется и возвращается *models.ConflictError.
+// Удаление отклоняется, если на удаляемые продукты ссылаются оставшиеся.
+func (a *App) DeleteProducts(versions map[int]int) error {
+	ids := make([]int, 0, len(versions))
+	for id := range versions {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return a.mutate(func(products *models.Products) error {
+		for _, id := range ids {
+			if err := a.checkVersion(products, id, versions[id]); err != nil {
+				return err
+			}
+		}
+		return a.deleteProducts(products, ids)
+	})
+}
+
+// deleteProducts удаляет продукты и записывает удаление в историю
+func (a *App) deleteProducts(products *models.Products, ids []int) error {
+	var removed []positionedProduct
+	for i, product := range *products {
+		if slices.Contains(ids, product.ID) {
+			removed = append(removed, positionedProduct{index: i, product: product})
+		}
+	}
+
+	if err := a.removeProducts(products, ids); err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	label := fmt.Sprintf("удаление %q", removed[0].product.Name)
+	if len(removed) > 1 {
+		label = fmt.Sprintf("удаление %d записей", len(removed))
+	}
+	a.history.Push(history.Entry{
+		Label: label,
+		Redo:  func() error { return a.removeProducts(products, ids) },
+		Undo:  func() error { return a.insertProducts(products, removed) },
+	})
+	return nil
+}
+
+// checkVersion проверяет, что продукт не изменяли с тех пор, как пользователь
+// получил его версию. Если хранилище умеет читать отдельные продукты, версия
+// сверяется с ним: базу SQLite могут одновременно изменять несколько копий
+// приложения. Изменения, найденные в хранилище, переносятся в список.
+func (a *App) checkVersion(products *models.Products, id, version int) error {
+	if incremental, ok := a.storage.(storage.IncrementalStorage); ok {
+		stored, err := incremental.Get(id)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			products.Delete(id)
+		case err != nil:
+			return err
+		default:
+			products.Update(stored)
+		}
+	}
+
+	index := products.IndexOf(id)
+	if index < 0 {
+		return &models.ConflictError{ID: id, Expected: version}
+	}
+	if current := (*products)[index]; current.Version != version {
+		return &models.ConflictError{ID: id, Expected: version, Current: &current}
+	}
+	return nil
+}
+
+// currentTime возвращает время изменения продукта с точностью до секунды
+func currentTime() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Undo отменяет последнее изменение продуктов.
+// Записи истории изменяют список, поэтому выполняются под его блокировкой.
+func (a *App) Undo() error {
+	return a.mutate(func(*models.Products) error {
+		return a.history.Undo()
+	})
+}
+
+// Redo повторяет последнее отмененное изменение продуктов
+func (a *App) Redo() error {
+	return a.mutate(func(*models.Products) error {
+		return a.history.Redo()
+	})
 }
 
-// DeleteProduct удаляет продукт по ID
-func (a *App) DeleteProduct(id int) error {
-	a.products.Delete(id)
-	return a.storage.Save(a.products)
+// GetHistoryState возвращает доступность отмены и повтора изменений
+func (a *App) GetHistoryState() history.State {
+	return a.history.State()
 }
 
-// DeleteProducts удаляет несколько продуктов по ID
-func (a *App) DeleteProducts(ids []int) error {
-	a.products.DeleteMultiple(ids)
-	return a.storage.Save(a.products)
+// positionedProduct продукт вместе с его позицией в списке
+type positionedProduct struct {
+	index   int
+	product models.Product
+}
+
+// insertProducts вставляет продукты на их прежние позиции и сохраняет изменения.
+// Позиции должны идти по возрастанию, тогда восстанавливается исходный порядок.
+func (a *App) insertProducts(products *models.Products, items []positionedProduct) error {
+	inserted := make([]models.Product, 0, len(items))
+	for _, item := range items {
+		products.Insert(item.index, item.product)
+		inserted = append(inserted, item.product)
+	}
+	return a.persistInsert(*products, inserted...)
+}
+
+// editProduct сохраняет изменение продукта пользователем: увеличивает версию
+// и обновляет время изменения
+func (a *App) editProduct(products *models.Products, product models.Product) error {
+	index := products.IndexOf(product.ID)
+	if index < 0 {
+		return fmt.Errorf("продукт с ID %d не найден", product.ID)
+	}
+	stored := (*products)[index].Version
+	product.Version = stored + 1
+	product.UpdatedAt = currentTime()
+	return a.replaceProduct(products, product, stored)
+}
+
+// replaceProduct заменяет продукт, пересчитывает время его и зависимых
+// от него продуктов и сохраняет изменения. stored - версия продукта в хранилище.
+func (a *App) replaceProduct(products *models.Products, product models.Product, stored int) error {
+	products.Update(product)
+	changed := a.recalculate(*products, append([]int{product.ID}, utils.Dependents(*products, product.ID)...))
+	if !slices.Contains(changed, product.ID) {
+		changed = append([]int{product.ID}, changed...)
+	}
+	return a.persistUpdate(*products, changed, map[int]int{product.ID: stored})
+}
+
+// removeProducts удаляет продукты, если на них не ссылаются оставшиеся, и сохраняет изменения
+func (a *App) removeProducts(products *models.Products, ids []int) error {
+	if err := checkReferences(*products, ids); err != nil {
+		return err
+	}
+	products.DeleteMultiple(ids)
+	return a.persistDelete(*products, ids)
+}
+
+// checkReferences проверяет, что на удаляемые продукты не ссылаются оставшиеся
+func checkReferences(products models.Products, ids []int) error {
+	for _, product := range products {
+		if slices.Contains(ids, product.ID) {
+			continue
+		}
+		for _, ref := range utils.FormulaReferences(product.TimeCalculation) {
+			if slices.Contains(ids, ref) {
+				return fmt.Errorf("нельзя удалить продукт #%d: %w: формула продукта #%d", ref, ErrReferenced, product.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// resolver создает вычислитель формул для списка продуктов с константами
+// и длительностью смены из настроек
+func (a *App) resolver(products models.Products) *utils.Resolver {
+	return utils.NewResolver(products, a.constants).WithShiftHours(a.settings.ShiftHours)
+}
+
+// recalculate пересчитывает время обработки указанных продуктов по их формулам
+// и возвращает ID продуктов, время которых изменилось
+func (a *App) recalculate(products models.Products, ids []int) []int {
+	resolver := a.resolver(products)
+	var changed []int
+	for i, product := range products {
+		if !slices.Contains(ids, product.ID) {
+			continue
+		}
+		processingTime, err := resolver.ProductTime(product.ID)
+		if err != nil {
+			log.Printf("Ошибка пересчета продукта %d: %v\n", product.ID, err)
+			continue
+		}
+		if product.ProcessingTime != processingTime {
+			products[i].ProcessingTime = processingTime
+			changed = append(changed, product.ID)
+		}
+	}
+	return changed
+}
+
+// persistInsert сохраняет добавленные продукты: отдельными записями,
+// если хранилище это поддерживает, иначе перезаписывает весь список
+func (a *App) persistInsert(products models.Products, inserted ...models.Product) error {
+	incremental, ok := a.storage.(storage.IncrementalStorage)
+	if !ok {
+		return a.storage.Save(products)
+	}
+	for _, product := range inserted {
+		if err := incremental.Insert(product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// persistUpdate сохраняет измененные продукты. stored - версии продуктов
+// в хранилище, если изменение увеличило их; версии остальных не изменились.
+func (a *App) persistUpdate(products models.Products, ids []int, stored map[int]int) error {
+	incremental, ok := a.storage.(storage.IncrementalStorage)
+	if !ok {
+		return a.storage.Save(products)
+	}
+	for _, product := range products {
+		if !slices.Contains(ids, product.ID) {
+			continue
+		}
+		version, ok := stored[product.ID]
+		if !ok {
+			version = product.Version
+		}
+		if err := incremental.Update(product, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// persistDelete сохраняет удаление продуктов
+func (a *App) persistDelete(products models.Products, ids []int) error {
+	if incremental, ok := a.storage.(storage.IncrementalStorage); ok {
+		return incremental.Delete(ids...)
+	}
+	return a.storage.Save(products)
+}
+
+// GetConstants возвращает все именованные константы формул
+func (a *App) GetConstants() []models.Constant {
+	var constants models.Constants
+	a.products.Read(func(models.Products) {
+		constants = slices.Clone(a.constants)
+	})
+	return constants
+}
+
+// AddConstant добавляет новую именованную константу
+func (a *App) AddConstant(name string, value float64) error {
+	if !utils.IsValidName(name) {
+		return fmt.Errorf("недопустимое имя константы %q", name)
+	}
+
+	return a.mutate(func(*models.Products) error {
+		if _, exists := a.constants.Get(name); exists {
+			return fmt.Errorf("константа %q уже существует", name)
+		}
+
+		a.constants.Set(models.Constant{Name: name, Value: value})
+		return a.saveConstants()
+	})
+}
+
+// UpdateConstant изменяет значение константы и пересчитывает время
+// обработки всех продуктов, формулы которых ее используют
+func (a *App) UpdateConstant(name string, value float64) error {
+	return a.mutate(func(products *models.Products) error {
+		if _, exists := a.constants.Get(name); !exists {
+			return fmt.Errorf("константа %q не найдена", name)
+		}
+
+		previous := slices.Clone(a.constants)
+		a.constants.Set(models.Constant{Name: name, Value: value})
+		if err := a.saveConstants(); err != nil {
+			return err
+		}
+
+		changed := a.recalculateDependents(*products, name)
+		if len(changed) == 0 {
+			return nil
+		}
+		if err := a.persistUpdate(*products, changed, nil); err != nil {
+			// Константа и пересчитанные продукты сохраняются вместе:
+			// без продуктов возвращаем прежнее значение константы
+			a.constants = previous
+			return errors.Join(err, a.saveConstants())
+		}
+		return nil
+	})
+}
+
+// DeleteConstant удаляет константу, если она не используется в формулах
+func (a *App) DeleteConstant(name string) error {
+	return a.mutate(func(products *models.Products) error {
+		if dependents := constantDependents(*products, name); len(dependents) > 0 {
+			return fmt.Errorf("константа %q используется в формулах продуктов с ID %v", name, dependents)
+		}
+
+		a.constants.Delete(name)
+		return a.saveConstants()
+	})
+}
+
+// saveConstants сохраняет константы, если хранилище это поддерживает
+func (a *App) saveConstants() error {
+	constantStorage, ok := a.storage.(storage.ConstantStorage)
+	if !ok {
+		return nil
+	}
+	return constantStorage.SaveConstants(a.constants)
+}
+
+// constantDependents возвращает ID продуктов, формулы которых используют константу
+func constantDependents(products models.Products, name string) []int {
+	var ids []int
+	for _, product := range products {
+		if slices.Contains(utils.FormulaVariables(product.TimeCalculation), name) {
+			ids = append(ids, product.ID)
+		}
+	}
+	return ids
+}
+
+// recalculateDependents пересчитывает время обработки продуктов, использующих
+// константу напрямую или через ссылки на другие продукты
+func (a *App) recalculateDependents(products models.Products, name string) []int {
+	ids := constantDependents(products, name)
+	return a.recalculate(products, append(ids, utils.Dependents(products, ids...)...))
+}
+
+// ListBackups возвращает резервные копии базы данных, начиная с самой новой
+func (a *App) ListBackups() ([]storage.Backup, error) {
+	var backups []storage.Backup
+	var err error
+	a.products.Read(func(models.Products) {
+		backupStorage, ok := a.storage.(storage.BackupStorage)
+		if !ok {
+			err = errors.New("хранилище не поддерживает резервные копии")
+			return
+		}
+		backups, err = backupStorage.ListBackups()
+	})
+	return backups, err
+}
+
+// RestoreBackup восстанавливает базу данных из резервной копии
+// и перезагружает продукты и константы
+func (a *App) RestoreBackup(id string) error {
+	return a.products.Write(func(products *models.Products) error {
+		backupStorage, ok := a.storage.(storage.BackupStorage)
+		if !ok {
+			return errors.New("хранилище не поддерживает резервные копии")
+		}
+		if err := a.writable(); err != nil {
+			return err
+		}
+		if err := backupStorage.RestoreBackup(id); err != nil {
+			return err
+		}
+		// История относится к данным до восстановления
+		a.history.Clear()
+		return a.reload(products)
+	})
+}
+
+// GetTimeMismatches возвращает продукты, время обработки которых в базе данных
+// не совпало с формулой при последней загрузке и еще не исправлено
+func (a *App) GetTimeMismatches() []TimeMismatch {
+	mismatches := []TimeMismatch{}
+	a.products.Read(func(models.Products) {
+		mismatches = append(mismatches, a.mismatches...)
+	})
+	return mismatches
+}
+
+// FixTimeMismatches записывает в базу данных время обработки, рассчитанное
+// по формулам, для всех продуктов с расхождением. Версии продуктов не меняются:
+// исправление не изменяет данные, которые видят пользователи.
+func (a *App) FixTimeMismatches() error {
+	return a.mutate(func(products *models.Products) error {
+		// Продукты с ошибкой в формуле исправляются только изменением формулы
+		var ids []int
+		var failed []TimeMismatch
+		for _, mismatch := range a.mismatches {
+			if mismatch.Error != "" {
+				failed = append(failed, mismatch)
+				continue
+			}
+			ids = append(ids, mismatch.ID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := a.persistUpdate(*products, ids, nil); err != nil {
+			return err
+		}
+		a.mismatches = failed
+		return nil
+	})
+}
+
+// GetLoadError возвращает описание ошибки последней загрузки базы данных
+// или пустую строку, если база загружена
+func (a *App) GetLoadError() string {
+	var message string
+	a.products.Read(func(models.Products) {
+		if a.loadErr != nil {
+			message = a.loadErr.Error()
+		}
+	})
+	return message
+}
+
+// ReloadDatabase перечитывает открытую базу данных, например после того,
+// как файл, который не удалось загрузить, исправлен в другой программе
+func (a *App) ReloadDatabase() error {
+	return a.products.Write(func(products *models.Products) error {
+		// История относится к данным до перечтения
+		a.history.Clear()
+		return a.reload(products)
+	})
+}
+
+// GetLoadReport возвращает проблемы, обнаруженные при последней загрузке базы данных,
+// и строки, которые не удалось загрузить как продукты
+func (a *App) GetLoadReport() storage.LoadReport {
+	report := storage.LoadReport{Issues: []storage.LoadIssue{}, Quarantine: []storage.QuarantinedRow{}}
+	a.products.Read(func(models.Products) {
+		if reporting, ok := a.storage.(storage.ReportingStorage); ok {
+			report = reporting.LoadReport()
+		}
+	})
+	return report
+}
+
+// ImportQuarantined добавляет исправленную строку карантина как новый продукт
+// и убирает ее из карантина. Продукт получает новый ID, так как исходный
+// был некорректным или уже занятым.
+func (a *App) ImportQuarantined(key, name, timeCalculation string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("наименование не может быть пустым")
+	}
+	return a.mutate(func(products *models.Products) error {
+		return a.updateQuarantine(key, func() error {
+			_, err := a.insertNewProduct(products, strings.TrimSpace(name), timeCalculation)
+			return err
+		})
+	})
+}
+
+// DiscardQuarantined окончательно удаляет строку карантина из базы данных
+func (a *App) DiscardQuarantined(key string) error {
+	return a.mutate(func(products *models.Products) error {
+		return a.updateQuarantine(key, func() error {
+			return a.storage.Save(*products)
+		})
+	})
+}
+
+// updateQuarantine убирает строку из карантина и вызывает save, который должен
+// сохранить базу данных. Если сохранить не удалось, строка возвращается в карантин.
+func (a *App) updateQuarantine(key string, save func() error) error {
+	reporting, ok := a.storage.(storage.ReportingStorage)
+	if !ok {
+		return errors.New("хранилище не поддерживает карантин")
+	}
+
+	quarantine := reporting.LoadReport().Quarantine
+	index := slices.IndexFunc(quarantine, func(row storage.QuarantinedRow) bool {
+		return row.Key == key
+	})
+	if index < 0 {
+		return fmt.Errorf("строка %s не найдена в карантине", key)
+	}
+
+	reporting.SetQuarantine(slices.Delete(slices.Clone(quarantine), index, index+1))
+	if err := save(); err != nil {
+		reporting.SetQuarantine(quarantine)
+		return err
+	}
+	return nil
+}
+
+// GetDatabasePath возвращает путь к открытой базе данных
+func (a *App) GetDatabasePath() string {
+	var path string
+	a.products.Read(func(models.Products) {
+		path = a.settings.DatabasePath
+	})
+	return path
+}
+
+// OpenDatabase предлагает выбрать файл базы данных и открывает его вместо текущего.
+// Возвращает путь к открытой базе или пустую строку, если выбор отменен.
+func (a *App) OpenDatabase() (string, error) {
+	filename, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:            "Открыть базу данных",
+		DefaultDirectory: a.databaseDir(),
+		Filters:          databaseFilters,
+	})
+	if err != nil {
+		return "", fmt.Errorf("ошибка при выборе файла: %w", err)
+	}
+	if filename == "" {
+		return "", nil
+	}
+	return filename, a.switchDatabase(filename)
+}
+
+// NewDatabase предлагает выбрать имя нового файла базы данных, создает его
+// и открывает вместо текущего. Тип базы определяется расширением файла.
+// Возвращает путь к новой базе или пустую строку, если выбор отменен.
+func (a *App) NewDatabase() (string, error) {
+	filename, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:            "Новая база данных",
+		DefaultDirectory: a.databaseDir(),
+		DefaultFilename:  "database.xlsx",
+		Filters:          databaseFilters,
+	})
+	if err != nil {
+		return "", fmt.Errorf("ошибка при выборе файла: %w", err)
+	}
+	if filename == "" {
+		return "", nil
+	}
+	// Существующий файл не перезаписываем, чтобы не потерять данные
+	if _, err := os.Stat(filename); err == nil {
+		return "", fmt.Errorf("файл %s уже существует, откройте его как базу данных", filename)
+	}
+	return filename, a.switchDatabase(filename)
+}
+
+// GetRecentDatabases возвращает недавно открытые базы данных, начиная с последней
+func (a *App) GetRecentDatabases() []string {
+	var recent []string
+	a.products.Read(func(models.Products) {
+		recent = slices.Clone(a.settings.RecentDatabases)
+	})
+	return recent
+}
+
+// SwitchDatabase открывает базу данных из списка недавно открытых вместо текущей.
+// База, файл которой больше не существует, удаляется из списка.
+func (a *App) SwitchDatabase(filename string) error {
+	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+		if err := a.products.Write(func(*models.Products) error {
+			a.settings.RemoveRecent(filename)
+			return a.saveSettings()
+		}); err != nil {
+			log.Printf("Ошибка сохранения настроек: %v\n", err)
+		}
+		return fmt.Errorf("база данных %s не найдена и удалена из списка недавних", filename)
+	}
+	return a.switchDatabase(filename)
+}
+
+// databaseDir возвращает каталог открытой базы данных для диалогов выбора файла
+func (a *App) databaseDir() string {
+	if path := a.GetDatabasePath(); path != "" {
+		return filepath.Dir(path)
+	}
+	return ""
+}
+
+// switchDatabase открывает другую базу данных вместо текущей и запоминает ее
+// в настройках. Если новую базу загрузить не удалось, остается открытой прежняя.
+func (a *App) switchDatabase(filename string) error {
+	if absolute, err := filepath.Abs(filename); err == nil {
+		filename = absolute
+	}
+
+	return a.products.Write(func(products *models.Products) error {
+		// Повторное открытие текущей базы наткнулось бы на собственную блокировку,
+		// а закрытие прежнего хранилища удалило бы ее
+		if a.settings.IsCurrentDatabase(filename) {
+			return nil
+		}
+
+		previous, previousConstants, previousLoadErr := a.storage, a.constants, a.loadErr
+		a.storage = a.newStorage(filename)
+		a.constants = nil
+
+		if err := a.reload(products); err != nil {
+			a.storage.Close()
+			a.storage, a.constants, a.loadErr = previous, previousConstants, previousLoadErr
+			a.checkLock()
+			return fmt.Errorf("ошибка при открытии базы данных %s: %w", filename, err)
+		}
+		if err := previous.Close(); err != nil {
+			log.Printf("Ошибка закрытия базы данных: %v\n", err)
+		}
+
+		// История относится к прежней базе
+		a.history.Clear()
+		a.settings.UseDatabase(filename)
+		// Ошибка только записывается в журнал: база уже открыта
+		if err := a.saveSettings(); err != nil {
+			log.Printf("Ошибка сохранения настроек: %v\n", err)
+		}
+		return nil
+	})
+}
+
+// saveSettings сохраняет настройки в файл
+func (a *App) saveSettings() error {
+	if a.settingsPath == "" {
+		return nil
+	}
+	return config.Save(a.settingsPath, a.settings)
+}
+
+// reload перечитывает продукты и константы из хранилища
+func (a *App) reload(target *models.Products) error {
+	products, err := a.storage.Load()
+	if err != nil {
+		a.loadErr = fmt.Errorf("ошибка загрузки данных: %w", err)
+		return a.loadErr
+	}
+
+	constants := a.constants
+	if constantStorage, ok := a.storage.(storage.ConstantStorage); ok {
+		constants, err = constantStorage.LoadConstants()
+		if err != nil {
+			a.loadErr = fmt.Errorf("ошибка загрузки констант: %w", err)
+			return a.loadErr
+		}
+	}
+
+	a.loadErr = nil
+	a.constants = constants
+	a.mismatches = a.checkTimes(products)
+	*target = products
+	a.checkLock()
+	return nil
+}
+
+// checkTimes пересчитывает время обработки загруженных продуктов по формулам,
+// так как время могли изменить в файле вручную, и возвращает продукты,
+// у которых сохраненное время не совпало с рассчитанным, а также продукты,
+// формулу которых не удалось вычислить. Время продуктов без формулы
+// и с ошибкой в формуле остается прежним.
+func (a *App) checkTimes(products models.Products) []TimeMismatch {
+	resolver := a.resolver(products)
+	var mismatches []TimeMismatch
+	for i, product := range products {
+		if strings.TrimSpace(product.TimeCalculation) == "" {
+			continue
+		}
+		mismatch := TimeMismatch{
+			ID:              product.ID,
+			Name:            product.Name,
+			TimeCalculation: product.TimeCalculation,
+			StoredTime:      product.ProcessingTime,
+		}
+		processingTime, err := resolver.ProductTime(product.ID)
+		switch {
+		case err != nil:
+			mismatch.Error = err.Error()
+		case processingTime != product.ProcessingTime:
+			products[i].ProcessingTime = processingTime
+			mismatch.CalculatedTime = processingTime
+		default:
+			continue
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	return mismatches
+}
+
+// checkLock переводит приложение в режим только для чтения, если базу данных
+// удерживает другая копия приложения, и возвращает в обычный режим, когда
+// блокировку удалось получить при очередной загрузке
+func (a *App) checkLock() {
+	locking, ok := a.storage.(storage.LockingStorage)
+	if !ok {
+		a.lockErr = nil
+		return
+	}
+
+	lockErr := locking.LockError()
+	if lockErr != nil && a.lockErr == nil {
+		log.Printf("База данных открыта только для чтения: %v\n", lockErr)
+	}
+	a.lockErr = lockErr
+}
+
+// refreshLock проверяет, не забрала ли блокировку базы данных другая копия
+// приложения после загрузки, и сообщает фронтенду о запрете изменений
+func (a *App) refreshLock() {
+	var lost error
+	a.products.Write(func(*models.Products) error {
+		held := a.lockErr == nil
+		a.checkLock()
+		if held && a.lockErr != nil {
+			lost = a.lockErr
+		}
+		return nil
+	})
+	if lost != nil {
+		a.emit(a.ctx, EventLockLost, lost.Error())
+	}
+}
+
+// writable возвращает *ReadOnlyError, если изменения запрещены
+func (a *App) writable() error {
+	if a.readOnly {
+		return &ReadOnlyError{}
+	}
+	if a.loadErr != nil {
+		return &ReadOnlyError{Reason: a.loadErr}
+	}
+	if a.lockErr != nil {
+		return &ReadOnlyError{Reason: a.lockErr}
+	}
+	return nil
 }
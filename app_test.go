@@ -2,15 +2,43 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/Mr-Cheen1/go-reg-wails/backend/config"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/history"
 	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/storage"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/utils"
+	"github.com/xuri/excelize/v2"
 )
 
-// MockStorage - мок для Storage
+// MockStorage - мок для Storage и ConstantStorage
 type MockStorage struct {
-	products models.Products
-	saveFunc func(models.Products) error
+	products  models.Products
+	constants models.Constants
+	saveFunc  func(models.Products) error
+}
+
+// versionsOf возвращает текущие версии продуктов для DeleteProducts
+func versionsOf(app *App, ids ...int) map[int]int {
+	versions := make(map[int]int, len(ids))
+	for _, id := range ids {
+		versions[id] = 0
+		for _, product := range app.GetProducts() {
+			if product.ID == id {
+				versions[id] = product.Version
+			}
+		}
+	}
+	return versions
 }
 
 func NewMockStorage(products models.Products) *MockStorage {
@@ -23,7 +51,7 @@ func NewMockStorage(products models.Products) *MockStorage {
 }
 
 func (ms *MockStorage) Load() (models.Products, error) {
-	return ms.products, nil
+	return slices.Clone(ms.products), nil
 }
 
 func (ms *MockStorage) Save(products models.Products) error {
@@ -34,6 +62,15 @@ func (ms *MockStorage) Close() error {
 	return nil
 }
 
+func (ms *MockStorage) LoadConstants() (models.Constants, error) {
+	return ms.constants, nil
+}
+
+func (ms *MockStorage) SaveConstants(constants models.Constants) error {
+	ms.constants = constants
+	return nil
+}
+
 // Вспомогательная функция для проверки равенства продуктов по важным полям
 func checkProductsEqual(t *testing.T, got, want models.Products, testName string) {
 	if len(got) != len(want) {
@@ -176,7 +213,7 @@ func TestApp_UpdateProduct(t *testing.T) {
 	app.Startup(context.Background())
 
 	// Обновляем существующий продукт
-	err := app.UpdateProduct(1, "Обновленный продукт", "3.5")
+	err := app.UpdateProduct(1, 0, "Обновленный продукт", "3.5")
 	if err != nil {
 		t.Fatalf("UpdateProduct() error = %v", err)
 	}
@@ -222,7 +259,7 @@ func TestApp_DeleteProduct(t *testing.T) {
 	app.Startup(context.Background())
 
 	// Удаляем продукт с ID=2
-	err := app.DeleteProduct(2)
+	err := app.DeleteProduct(2, 0)
 	if err != nil {
 		t.Fatalf("DeleteProduct() error = %v", err)
 	}
@@ -262,8 +299,19 @@ func TestApp_DeleteProducts(t *testing.T) {
 	app := NewApp(mockStorage)
 	app.Startup(context.Background())
 
+	// Продукт изменили после того, как пользователь его видел: ничего не удаляется
+	versions := versionsOf(app, 2, 4)
+	stale := map[int]int{2: versions[2], 4: versions[4] + 1}
+	var conflict *models.ConflictError
+	if err := app.DeleteProducts(stale); !errors.As(err, &conflict) || conflict.ID != 4 {
+		t.Fatalf("DeleteProducts() с устаревшей версией error = %v, want *models.ConflictError для #4", err)
+	}
+	if products := app.GetProducts(); len(products) != 4 {
+		t.Fatalf("После конфликта количество продуктов = %d, want 4", len(products))
+	}
+
 	// Удаляем несколько продуктов
-	err := app.DeleteProducts([]int{2, 4})
+	err := app.DeleteProducts(versions)
 	if err != nil {
 		t.Fatalf("DeleteProducts() error = %v", err)
 	}
@@ -281,3 +329,1450 @@ func TestApp_DeleteProducts(t *testing.T) {
 		}
 	}
 }
+
+func TestApp_AddProductWithExpression(t *testing.T) {
+	mockStorage := NewMockStorage(models.Products{})
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	if err := app.AddProduct("Деталь", "(4-1)/2 + 2*3 + 0,5"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+
+	products := app.GetProducts()
+	if len(products) != 1 {
+		t.Fatalf("После AddProduct() количество продуктов = %d, want %d", len(products), 1)
+	}
+	if products[0].ProcessingTime != 8 {
+		t.Errorf("Время обработки = %f, want %f", products[0].ProcessingTime, 8.0)
+	}
+}
+
+func TestApp_AddProductInvalidFormula(t *testing.T) {
+	mockStorage := NewMockStorage(models.Products{})
+	saved := false
+	mockStorage.saveFunc = func(products models.Products) error {
+		saved = true
+		return nil
+	}
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	err := app.AddProduct("Деталь", "2+abc+3")
+	var formulaErr *utils.FormulaError
+	if !errors.As(err, &formulaErr) {
+		t.Fatalf("AddProduct() error = %v, want *utils.FormulaError", err)
+	}
+	if formulaErr.Position != 3 || formulaErr.Token != "abc" {
+		t.Errorf("AddProduct() ошибка в позиции %d (%q), want 3 (\"abc\")", formulaErr.Position, formulaErr.Token)
+	}
+	if saved || len(app.GetProducts()) != 0 {
+		t.Errorf("Продукт с некорректной формулой не должен сохраняться")
+	}
+}
+
+func TestApp_UpdateProductInvalidFormula(t *testing.T) {
+	initialProducts := models.Products{
+		{ID: 1, Name: "Продукт 1", ProcessingTime: 1.5, TimeCalculation: "1.5"},
+	}
+	app := NewApp(NewMockStorage(initialProducts))
+	app.Startup(context.Background())
+
+	if err := app.UpdateProduct(1, 0, "Продукт 1", "(1+2"); err == nil {
+		t.Fatalf("UpdateProduct() с некорректной формулой должен вернуть ошибку")
+	}
+
+	product := app.GetProducts()[0]
+	if product.ProcessingTime != 1.5 || product.TimeCalculation != "1.5" {
+		t.Errorf("Продукт изменен некорректной формулой: %+v", product)
+	}
+}
+
+func TestApp_ValidateFormula(t *testing.T) {
+	app := NewApp(NewMockStorage(models.Products{}))
+
+	result := app.ValidateFormula("1,5*2")
+	if !result.Valid || result.ProcessingTime != 3 || result.Error != nil {
+		t.Errorf("ValidateFormula(\"1,5*2\") = %+v, want valid 3", result)
+	}
+
+	result = app.ValidateFormula("2+")
+	if result.Valid || result.Error == nil {
+		t.Fatalf("ValidateFormula(\"2+\") = %+v, want ошибку", result)
+	}
+	if result.Error.Position != 3 {
+		t.Errorf("ValidateFormula(\"2+\") позиция ошибки = %d, want %d", result.Error.Position, 3)
+	}
+}
+
+func TestApp_Constants(t *testing.T) {
+	initialProducts := models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 7.05, TimeCalculation: "setup_cnc + 3*2.1"},
+		{ID: 2, Name: "Втулка", ProcessingTime: 2, TimeCalculation: "2"},
+	}
+	mockStorage := NewMockStorage(initialProducts)
+	mockStorage.constants = models.Constants{{Name: "setup_cnc", Value: 0.75}}
+	savedProducts := models.Products{}
+	mockStorage.saveFunc = func(products models.Products) error {
+		savedProducts = products
+		return nil
+	}
+
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	if constants := app.GetConstants(); len(constants) != 1 || constants[0].Name != "setup_cnc" {
+		t.Fatalf("GetConstants() = %v, want константу setup_cnc", constants)
+	}
+
+	// Новый продукт может использовать константу
+	if err := app.AddProduct("Корпус", "setup_cnc*2 + 30мин"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+	if got := app.GetProducts()[2].ProcessingTime; got != 2 {
+		t.Errorf("Время обработки с константой = %v, want %v", got, 2.0)
+	}
+
+	// Изменение константы пересчитывает зависимые продукты
+	if err := app.UpdateConstant("setup_cnc", 1); err != nil {
+		t.Fatalf("UpdateConstant() error = %v", err)
+	}
+	expectedTimes := []float64{7.3, 2, 2.5}
+	for i, product := range savedProducts {
+		if product.ProcessingTime != expectedTimes[i] {
+			t.Errorf("Продукт %d: время обработки = %v, want %v", product.ID, product.ProcessingTime, expectedTimes[i])
+		}
+	}
+	if constant, _ := mockStorage.constants.Get("setup_cnc"); constant.Value != 1 {
+		t.Errorf("Сохраненное значение константы = %v, want %v", constant.Value, 1.0)
+	}
+
+	// Используемую константу удалить нельзя
+	if err := app.DeleteConstant("setup_cnc"); err == nil {
+		t.Errorf("DeleteConstant() используемой константы должен вернуть ошибку")
+	}
+
+	if err := app.AddConstant("changeover", 0.5); err != nil {
+		t.Fatalf("AddConstant() error = %v", err)
+	}
+	if err := app.AddConstant("changeover", 1); err == nil {
+		t.Errorf("AddConstant() существующей константы должен вернуть ошибку")
+	}
+	if err := app.DeleteConstant("changeover"); err != nil {
+		t.Fatalf("DeleteConstant() error = %v", err)
+	}
+	if len(mockStorage.constants) != 1 {
+		t.Errorf("После DeleteConstant() сохранено констант = %d, want %d", len(mockStorage.constants), 1)
+	}
+}
+
+func TestApp_UpdateConstantFailedSave(t *testing.T) {
+	initialProducts := models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 7.05, TimeCalculation: "setup_cnc + 3*2.1"},
+	}
+	mockStorage := NewMockStorage(initialProducts)
+	mockStorage.constants = models.Constants{{Name: "setup_cnc", Value: 0.75}}
+	saveErr := errors.New("диск заполнен")
+	mockStorage.saveFunc = func(models.Products) error { return saveErr }
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	if err := app.UpdateConstant("setup_cnc", 1); !errors.Is(err, saveErr) {
+		t.Fatalf("UpdateConstant() error = %v, want %v", err, saveErr)
+	}
+	// Константа не сохраняется отдельно от пересчитанных продуктов
+	if constant, _ := mockStorage.constants.Get("setup_cnc"); constant.Value != 0.75 {
+		t.Errorf("Сохраненное значение константы = %v, want %v", constant.Value, 0.75)
+	}
+	if constant, _ := models.Constants(app.GetConstants()).Get("setup_cnc"); constant.Value != 0.75 {
+		t.Errorf("GetConstants() значение = %v, want %v", constant.Value, 0.75)
+	}
+	if got := app.GetProducts()[0].ProcessingTime; got != 7.05 {
+		t.Errorf("Время обработки = %v, want %v", got, 7.05)
+	}
+}
+
+func TestApp_AddConstantInvalidName(t *testing.T) {
+	app := NewApp(NewMockStorage(models.Products{}))
+	app.Startup(context.Background())
+
+	for _, name := range []string{"", "1abc", "setup-cnc", "мин"} {
+		if err := app.AddConstant(name, 1); err == nil {
+			t.Errorf("AddConstant(%q) должен вернуть ошибку", name)
+		}
+	}
+}
+
+func TestApp_ProductReferences(t *testing.T) {
+	initialProducts := models.Products{
+		{ID: 12, Name: "Вал", ProcessingTime: 1.5, TimeCalculation: "1.5"},
+		{ID: 15, Name: "Втулка", ProcessingTime: 0.5, TimeCalculation: "30мин"},
+	}
+	mockStorage := NewMockStorage(initialProducts)
+	savedProducts := models.Products{}
+	mockStorage.saveFunc = func(products models.Products) error {
+		savedProducts = products
+		return nil
+	}
+
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	// Сборка из деталей
+	if err := app.AddProduct("Сборка", "#12 + #15*2 + 0.5"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+	if got := savedProducts[2].ProcessingTime; got != 3 {
+		t.Errorf("Время сборки = %v, want %v", got, 3.0)
+	}
+
+	// Изменение детали пересчитывает сборку
+	if err := app.UpdateProduct(12, 0, "Вал", "2"); err != nil {
+		t.Fatalf("UpdateProduct() error = %v", err)
+	}
+	if got := savedProducts[2].ProcessingTime; got != 3.5 {
+		t.Errorf("Время сборки после изменения детали = %v, want %v", got, 3.5)
+	}
+
+	// Циклическая ссылка отклоняется
+	if err := app.UpdateProduct(12, 1, "Вал", "#16 + 1"); err == nil {
+		t.Errorf("UpdateProduct() с циклической ссылкой должен вернуть ошибку")
+	}
+	if err := app.AddProduct("Узел", "#99"); err == nil {
+		t.Errorf("AddProduct() со ссылкой на несуществующий продукт должен вернуть ошибку")
+	}
+
+	// Нельзя удалить деталь, на которую ссылается сборка
+	if err := app.DeleteProduct(15, 0); err == nil {
+		t.Errorf("DeleteProduct() используемой детали должен вернуть ошибку")
+	}
+	if err := app.DeleteProducts(versionsOf(app, 12, 15)); err == nil {
+		t.Errorf("DeleteProducts() используемых деталей должен вернуть ошибку")
+	}
+	if len(app.GetProducts()) != 3 {
+		t.Fatalf("Отклоненное удаление не должно менять список продуктов")
+	}
+
+	// Сборку можно удалить вместе с деталями
+	if err := app.DeleteProducts(versionsOf(app, 12, 15, 16)); err != nil {
+		t.Fatalf("DeleteProducts() error = %v", err)
+	}
+	if len(savedProducts) != 0 {
+		t.Errorf("После DeleteProducts() количество продуктов = %d, want %d", len(savedProducts), 0)
+	}
+}
+
+func TestApp_ConstantRecalculatesReferences(t *testing.T) {
+	initialProducts := models.Products{
+		{ID: 1, Name: "Деталь", ProcessingTime: 1.75, TimeCalculation: "setup + 1"},
+		{ID: 2, Name: "Сборка", ProcessingTime: 3.5, TimeCalculation: "#1 * 2"},
+	}
+	mockStorage := NewMockStorage(initialProducts)
+	mockStorage.constants = models.Constants{{Name: "setup", Value: 0.75}}
+
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	if err := app.UpdateConstant("setup", 1); err != nil {
+		t.Fatalf("UpdateConstant() error = %v", err)
+	}
+	if got := app.GetProducts()[1].ProcessingTime; got != 4 {
+		t.Errorf("Время сборки после изменения константы = %v, want %v", got, 4.0)
+	}
+}
+
+// MockIncrementalStorage - мок для IncrementalStorage, записывающий вызовы
+type MockIncrementalStorage struct {
+	*MockStorage
+	calls []string
+}
+
+func NewMockIncrementalStorage(products models.Products) *MockIncrementalStorage {
+	ms := &MockIncrementalStorage{MockStorage: NewMockStorage(products)}
+	ms.saveFunc = func(models.Products) error {
+		ms.calls = append(ms.calls, "Save")
+		return nil
+	}
+	return ms
+}
+
+func (ms *MockIncrementalStorage) Get(id int) (models.Product, error) {
+	for _, product := range ms.products {
+		if product.ID == id {
+			return product, nil
+		}
+	}
+	return models.Product{}, storage.ErrNotFound
+}
+
+func (ms *MockIncrementalStorage) Insert(product models.Product) error {
+	ms.calls = append(ms.calls, fmt.Sprintf("Insert %d", product.ID))
+	ms.products = append(ms.products, product)
+	return nil
+}
+
+func (ms *MockIncrementalStorage) Update(product models.Product, version int) error {
+	ms.calls = append(ms.calls, fmt.Sprintf("Update %d", product.ID))
+	stored, err := ms.Get(product.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Version != version {
+		return storage.ErrConflict
+	}
+	ms.products.Update(product)
+	return nil
+}
+
+func (ms *MockIncrementalStorage) Delete(ids ...int) error {
+	ms.calls = append(ms.calls, fmt.Sprintf("Delete %v", ids))
+	ms.products.DeleteMultiple(ids)
+	return nil
+}
+
+func TestApp_IncrementalStorage(t *testing.T) {
+	initialProducts := models.Products{
+		{ID: 1, Name: "Деталь", ProcessingTime: 1, TimeCalculation: "1"},
+		{ID: 2, Name: "Сборка", ProcessingTime: 2, TimeCalculation: "#1*2"},
+		{ID: 3, Name: "Продукт 3", ProcessingTime: 3, TimeCalculation: "3"},
+	}
+	mockStorage := NewMockIncrementalStorage(initialProducts)
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	if err := app.AddProduct("Новый продукт", "4"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+	// Изменение детали сохраняет и пересчитанную сборку
+	if err := app.UpdateProduct(1, 0, "Деталь", "1.5"); err != nil {
+		t.Fatalf("UpdateProduct() error = %v", err)
+	}
+	if err := app.DeleteProduct(4, 1); err != nil {
+		t.Fatalf("DeleteProduct() error = %v", err)
+	}
+	if err := app.DeleteProducts(versionsOf(app, 2, 3)); err != nil {
+		t.Fatalf("DeleteProducts() error = %v", err)
+	}
+
+	expected := []string{"Insert 4", "Update 1", "Update 2", "Delete [4]", "Delete [2 3]"}
+	if !reflect.DeepEqual(mockStorage.calls, expected) {
+		t.Errorf("Вызовы хранилища = %v, want %v", mockStorage.calls, expected)
+	}
+}
+
+func TestApp_RestoreBackupAfterBulkDelete(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	excelStorage := storage.NewExcelStorage().WithFilename(filename).WithBackups(storage.BackupPolicy{KeepLast: 10})
+	defer excelStorage.Close()
+
+	app := NewApp(excelStorage)
+	app.Startup(context.Background())
+	for _, name := range []string{"Продукт 1", "Продукт 2", "Продукт 3"} {
+		if err := app.AddProduct(name, "1"); err != nil {
+			t.Fatalf("AddProduct() error = %v", err)
+		}
+	}
+
+	if err := app.DeleteProducts(versionsOf(app, 1, 2, 3)); err != nil {
+		t.Fatalf("DeleteProducts() error = %v", err)
+	}
+	if len(app.GetProducts()) != 0 {
+		t.Fatalf("После DeleteProducts() остались продукты: %v", app.GetProducts())
+	}
+
+	backups, err := app.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) == 0 {
+		t.Fatalf("ListBackups() не вернул резервных копий")
+	}
+
+	// Самая новая копия сделана перед массовым удалением
+	if err := app.RestoreBackup(backups[0].ID); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if products := app.GetProducts(); len(products) != 3 {
+		t.Errorf("После RestoreBackup() количество продуктов = %d, want %d", len(products), 3)
+	}
+}
+
+func TestApp_BackupsUnsupported(t *testing.T) {
+	app := NewApp(NewMockStorage(models.Products{}))
+	if _, err := app.ListBackups(); err == nil {
+		t.Errorf("ListBackups() для хранилища без резервных копий должен вернуть ошибку")
+	}
+	if err := app.RestoreBackup("backup.xlsx"); err == nil {
+		t.Errorf("RestoreBackup() для хранилища без резервных копий должен вернуть ошибку")
+	}
+}
+
+func TestApp_UndoBulkDeleteRestoresIDsAndOrder(t *testing.T) {
+	testProducts := models.Products{
+		{ID: 5, Name: "Продукт 5", ProcessingTime: 1, TimeCalculation: "1"},
+		{ID: 2, Name: "Продукт 2", ProcessingTime: 2, TimeCalculation: "2"},
+		{ID: 9, Name: "Продукт 9", ProcessingTime: 3, TimeCalculation: "3"},
+		{ID: 4, Name: "Продукт 4", ProcessingTime: 4, TimeCalculation: "4"},
+		{ID: 7, Name: "Продукт 7", ProcessingTime: 5, TimeCalculation: "5"},
+	}
+	original := append([]models.Product{}, testProducts...)
+
+	mockStorage := NewMockIncrementalStorage(testProducts)
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	if err := app.DeleteProducts(versionsOf(app, 2, 4, 7)); err != nil {
+		t.Fatalf("DeleteProducts() error = %v", err)
+	}
+	if err := app.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if got := app.GetProducts(); !reflect.DeepEqual(got, original) {
+		t.Errorf("После Undo() продукты = %v, want %v", got, original)
+	}
+	if state := app.GetHistoryState(); state.CanUndo || !state.CanRedo {
+		t.Errorf("GetHistoryState() = %+v, want только повтор", state)
+	}
+
+	if err := app.Redo(); err != nil {
+		t.Fatalf("Redo() error = %v", err)
+	}
+	want := []models.Product{original[0], original[2]}
+	if got := app.GetProducts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("После Redo() продукты = %v, want %v", got, want)
+	}
+
+	wantCalls := []string{"Delete [2 4 7]", "Insert 2", "Insert 4", "Insert 7", "Delete [2 4 7]"}
+	if !reflect.DeepEqual(mockStorage.calls, wantCalls) {
+		t.Errorf("Вызовы хранилища = %v, want %v", mockStorage.calls, wantCalls)
+	}
+}
+
+func TestApp_UndoRedoAddAndUpdate(t *testing.T) {
+	app := NewApp(NewMockStorage(models.Products{}))
+	app.Startup(context.Background())
+
+	if err := app.AddProduct("Вал", "2"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+	if err := app.AddProduct("Втулка", "#1 + 1"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+	if err := app.UpdateProduct(1, 1, "Вал длинный", "4"); err != nil {
+		t.Fatalf("UpdateProduct() error = %v", err)
+	}
+
+	// Отмена изменения возвращает и сам продукт, и время зависимого от него
+	if err := app.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	products := app.GetProducts()
+	if products[0].Name != "Вал" || products[0].ProcessingTime != 2 || products[1].ProcessingTime != 3 {
+		t.Errorf("После отмены изменения продукты = %v", products)
+	}
+
+	// Отмена добавления
+	if err := app.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if products := app.GetProducts(); len(products) != 1 {
+		t.Errorf("После отмены добавления количество продуктов = %d, want 1", len(products))
+	}
+
+	// Новое изменение сбрасывает возможность повтора
+	if err := app.AddProduct("Шайба", "1"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+	if err := app.Redo(); !errors.Is(err, history.ErrNothingToRedo) {
+		t.Errorf("Redo() error = %v, want %v", err, history.ErrNothingToRedo)
+	}
+}
+
+func TestApp_UndoFailedChangeNotRecorded(t *testing.T) {
+	app := NewApp(NewMockStorage(models.Products{}))
+	app.Startup(context.Background())
+
+	if err := app.AddProduct("Вал", "2"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+	if err := app.AddProduct("Втулка", "#1 + 1"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+
+	// Отклоненное удаление не попадает в историю
+	if err := app.DeleteProduct(1, 1); err == nil {
+		t.Fatalf("DeleteProduct() должен вернуть ошибку для продукта со ссылками")
+	}
+	if state := app.GetHistoryState(); state.UndoLabel != `добавление "Втулка"` {
+		t.Errorf("GetHistoryState().UndoLabel = %q, want %q", state.UndoLabel, `добавление "Втулка"`)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := app.Undo(); err != nil {
+			t.Fatalf("Undo() error = %v", err)
+		}
+	}
+	if products := app.GetProducts(); len(products) != 0 {
+		t.Errorf("После отмены всех изменений остались продукты: %v", products)
+	}
+	if err := app.Undo(); !errors.Is(err, history.ErrNothingToUndo) {
+		t.Errorf("Undo() error = %v, want %v", err, history.ErrNothingToUndo)
+	}
+}
+
+func TestApp_FailedSaveRollsBack(t *testing.T) {
+	initialProducts := models.Products{
+		{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1", Version: 1},
+	}
+	mockStorage := NewMockStorage(initialProducts)
+	saveErr := errors.New("диск заполнен")
+	mockStorage.saveFunc = func(models.Products) error { return saveErr }
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	mutations := map[string]func() error{
+		"AddProduct":     func() error { return app.AddProduct("Продукт 2", "2") },
+		"UpdateProduct":  func() error { return app.UpdateProduct(1, 1, "Изменен", "3") },
+		"DeleteProducts": func() error { return app.DeleteProducts(versionsOf(app, 1)) },
+	}
+	for name, mutate := range mutations {
+		if err := mutate(); !errors.Is(err, saveErr) {
+			t.Errorf("%s() error = %v, want %v", name, err, saveErr)
+		}
+		// Несохраненное изменение не остается в памяти и в истории
+		if got := app.GetProducts(); !reflect.DeepEqual(got, []models.Product(initialProducts)) {
+			t.Errorf("После ошибки %s() продукты = %v, want %v", name, got, initialProducts)
+		}
+	}
+	if state := app.GetHistoryState(); state.CanUndo {
+		t.Errorf("GetHistoryState() = %+v, want пустая история", state)
+	}
+
+	// Повтор с той же версией после устранения сбоя не считается конфликтом
+	mockStorage.saveFunc = func(models.Products) error { return nil }
+	if err := app.UpdateProduct(1, 1, "Изменен", "3"); err != nil {
+		t.Fatalf("UpdateProduct() после сбоя error = %v", err)
+	}
+	if got := app.GetProducts()[0]; got.Name != "Изменен" || got.Version != 2 {
+		t.Errorf("GetProducts()[0] = %+v, want Изменен версии 2", got)
+	}
+}
+
+func TestApp_ConcurrentMutations(t *testing.T) {
+	mockStorage := NewMockIncrementalStorage(models.Products{})
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	const workers = 8
+	const perWorker = 25
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if err := app.AddProduct("Продукт", "1 + 1"); err != nil {
+					t.Errorf("AddProduct() error = %v", err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				products := app.GetProducts()
+				if len(products) == 0 {
+					continue
+				}
+				// Тот же продукт мог удалить другой поток: это конфликт версий
+				err := app.DeleteProducts(map[int]int{products[0].ID: products[0].Version})
+				var conflict *models.ConflictError
+				if err != nil && !errors.As(err, &conflict) {
+					t.Errorf("DeleteProducts() error = %v", err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				_ = app.SearchProducts("Прод")
+				_ = app.ValidateFormula("2 * 3")
+				_ = app.GetHistoryState()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// ID не повторяются, а каждое добавление и удаление дошло до хранилища
+	products := app.GetProducts()
+	seen := make(map[int]bool)
+	for _, product := range products {
+		if seen[product.ID] {
+			t.Errorf("ID %d встречается несколько раз", product.ID)
+		}
+		seen[product.ID] = true
+	}
+
+	// Изменения выполняются по одному, поэтому повтор вызовов хранилища
+	// по порядку должен дать тот же набор продуктов
+	inserts := 0
+	replayed := make(map[int]bool)
+	for _, call := range mockStorage.calls {
+		var id int
+		if _, err := fmt.Sscanf(call, "Insert %d", &id); err == nil {
+			inserts++
+			replayed[id] = true
+		} else if _, err := fmt.Sscanf(call, "Delete [%d]", &id); err == nil {
+			delete(replayed, id)
+		}
+	}
+	if inserts != workers*perWorker {
+		t.Errorf("Вызовов Insert = %d, want %d", inserts, workers*perWorker)
+	}
+	if !reflect.DeepEqual(seen, replayed) {
+		t.Errorf("Продукты = %v, по вызовам хранилища want %v", seen, replayed)
+	}
+}
+
+func TestApp_GetProductsReturnsCopy(t *testing.T) {
+	app := NewApp(NewMockStorage(models.Products{{ID: 1, Name: "Продукт 1", ProcessingTime: 1}}))
+	app.Startup(context.Background())
+
+	products := app.GetProducts()
+	products[0].Name = "Изменен"
+	if got := app.GetProducts()[0].Name; got != "Продукт 1" {
+		t.Errorf("GetProducts()[0].Name = %q, want %q", got, "Продукт 1")
+	}
+}
+
+// newExcelApp создает приложение с Excel файлом во временной директории
+// и перехватывает события фронтенда
+func newExcelApp(t *testing.T, products models.Products) (*App, string, *[]string) {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	if err := storage.NewExcelStorage().WithFilename(filename).Save(products); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	excelStorage := storage.NewExcelStorage().WithFilename(filename)
+	t.Cleanup(func() { excelStorage.Close() })
+
+	app := NewApp(excelStorage)
+	var events []string
+	app.emit = func(_ context.Context, name string, _ ...interface{}) {
+		events = append(events, name)
+	}
+	if err := app.products.Write(app.reload); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	return app, filename, &events
+}
+
+func TestApp_ReloadIfChanged(t *testing.T) {
+	app, filename, events := newExcelApp(t, models.Products{
+		{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1"},
+	})
+
+	if changed, err := app.reloadIfChanged(); err != nil || changed {
+		t.Fatalf("reloadIfChanged() = %v, %v, want false", changed, err)
+	}
+
+	external := []models.Product{
+		{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1"},
+		{ID: 2, Name: "Добавлен в Excel", ProcessingTime: 2, TimeCalculation: "2"},
+	}
+	if err := storage.NewExcelStorage().WithFilename(filename).Save(external); err != nil {
+		t.Fatalf("Save() другой программой error = %v", err)
+	}
+
+	if changed, err := app.reloadIfChanged(); err != nil || !changed {
+		t.Fatalf("reloadIfChanged() = %v, %v, want true", changed, err)
+	}
+	if got := app.GetProducts(); !reflect.DeepEqual(got, external) {
+		t.Errorf("GetProducts() = %v, want %v", got, external)
+	}
+	if want := []string{EventProductsReloaded}; !reflect.DeepEqual(*events, want) {
+		t.Errorf("События = %v, want %v", *events, want)
+	}
+}
+
+func TestApp_SaveConflictReloads(t *testing.T) {
+	app, filename, events := newExcelApp(t, models.Products{
+		{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1"},
+	})
+	if err := app.AddProduct("Продукт 2", "2"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+
+	// Файл изменен другой программой до того, как приложение это заметило
+	external := []models.Product{
+		{ID: 1, Name: "Изменен в Excel", ProcessingTime: 5, TimeCalculation: "5"},
+	}
+	if err := storage.NewExcelStorage().WithFilename(filename).Save(external); err != nil {
+		t.Fatalf("Save() другой программой error = %v", err)
+	}
+
+	err := app.UpdateProduct(1, 0, "Изменен в приложении", "3")
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("UpdateProduct() error = %v, want %v", err, storage.ErrConflict)
+	}
+	if got := app.GetProducts(); !reflect.DeepEqual(got, external) {
+		t.Errorf("После конфликта GetProducts() = %v, want %v", got, external)
+	}
+	if want := []string{EventProductsReloaded}; !reflect.DeepEqual(*events, want) {
+		t.Errorf("События = %v, want %v", *events, want)
+	}
+	if state := app.GetHistoryState(); state.CanUndo {
+		t.Errorf("После перечитывания файла история должна быть пуста: %+v", state)
+	}
+
+	// Повторное изменение сохраняется поверх принятых изменений
+	if err := app.UpdateProduct(1, 0, "Изменен в приложении", "3"); err != nil {
+		t.Fatalf("UpdateProduct() error = %v", err)
+	}
+}
+
+func TestApp_ProductVersions(t *testing.T) {
+	app := NewApp(NewMockStorage(models.Products{}))
+	app.Startup(context.Background())
+
+	if err := app.AddProduct("Вал", "2"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+	added := app.GetProducts()[0]
+	if added.Version != 1 || added.UpdatedAt.IsZero() {
+		t.Fatalf("Новый продукт: версия %d, время изменения %v", added.Version, added.UpdatedAt)
+	}
+
+	if err := app.UpdateProduct(1, 1, "Вал", "3"); err != nil {
+		t.Fatalf("UpdateProduct() error = %v", err)
+	}
+	if version := app.GetProducts()[0].Version; version != 2 {
+		t.Errorf("После изменения версия = %d, want %d", version, 2)
+	}
+
+	// Второй пользователь изменяет продукт по устаревшей версии
+	err := app.UpdateProduct(1, 1, "Вал", "4")
+	var conflict *models.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("UpdateProduct() по устаревшей версии error = %v, want *models.ConflictError", err)
+	}
+	if conflict.Current == nil || conflict.Current.Version != 2 || conflict.Current.TimeCalculation != "3" {
+		t.Errorf("ConflictError.Current = %+v, want текущий продукт версии 2", conflict.Current)
+	}
+	if err := app.DeleteProduct(1, 1); !errors.As(err, &conflict) {
+		t.Errorf("DeleteProduct() по устаревшей версии error = %v, want *models.ConflictError", err)
+	}
+
+	// Отмена изменения тоже увеличивает версию
+	if err := app.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if product := app.GetProducts()[0]; product.Version != 3 || product.TimeCalculation != "2" {
+		t.Errorf("После Undo() продукт = %+v, want формулу 2 и версию 3", product)
+	}
+}
+
+func TestApp_VersionConflictWithStorage(t *testing.T) {
+	mockStorage := NewMockIncrementalStorage(models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 1, TimeCalculation: "1", Version: 1},
+		{ID: 2, Name: "Втулка", ProcessingTime: 2, TimeCalculation: "2", Version: 1},
+	})
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	// Другая копия приложения изменила вал и удалила втулку в общей базе
+	mockStorage.products = models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 5, TimeCalculation: "5", Version: 2},
+	}
+
+	err := app.UpdateProduct(1, 1, "Вал", "3")
+	var conflict *models.ConflictError
+	if !errors.As(err, &conflict) || conflict.Current == nil {
+		t.Fatalf("UpdateProduct() error = %v, want *models.ConflictError с текущим продуктом", err)
+	}
+	if got := app.GetProducts()[0]; got.Version != 2 || got.ProcessingTime != 5 {
+		t.Errorf("После конфликта продукт = %+v, want продукт из хранилища", got)
+	}
+
+	err = app.DeleteProduct(2, 1)
+	if !errors.As(err, &conflict) || conflict.Current != nil {
+		t.Fatalf("DeleteProduct() error = %v, want *models.ConflictError для удаленного продукта", err)
+	}
+	if products := app.GetProducts(); len(products) != 1 {
+		t.Errorf("Удаленный в хранилище продукт остался в списке: %v", products)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	current := &models.Product{ID: 1, Name: "Вал", Version: 2}
+	tests := []struct {
+		name string
+		err  error
+		want any
+	}{
+		{
+			name: "изменен другим пользователем",
+			err:  &models.ConflictError{ID: 1, Expected: 1, Current: current},
+			want: AppError{Code: ErrorCodeConflict, Message: "продукт #1 изменен другим пользователем: версия 2, ожидалась 1", Current: current},
+		},
+		{
+			name: "удален другим пользователем",
+			err:  fmt.Errorf("изменение: %w", &models.ConflictError{ID: 1, Expected: 1}),
+			want: AppError{Code: ErrorCodeConflict, Message: "изменение: продукт #1 удален другим пользователем"},
+		},
+		{
+			name: "файл изменен другой программой",
+			err:  storage.ErrConflict,
+			want: storage.ErrConflict.Error(),
+		},
+		{
+			name: "другая ошибка",
+			err:  ErrInvalidFormula,
+			want: ErrInvalidFormula.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatError(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("formatError() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApp_SQLiteConcurrentWriter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.db")
+	other := storage.NewSQLiteStorage().WithFilename(filename)
+	defer other.Close()
+	if err := other.Save(models.Products{
+		{ID: 1, Name: "Деталь", ProcessingTime: 1, TimeCalculation: "1", Version: 1},
+		{ID: 2, Name: "Сборка", ProcessingTime: 2, TimeCalculation: "#1*2", Version: 1},
+	}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	sqliteStorage := storage.NewSQLiteStorage().WithFilename(filename)
+	defer sqliteStorage.Close()
+	app := NewApp(sqliteStorage)
+	var events []string
+	app.emit = func(_ context.Context, name string, _ ...interface{}) {
+		events = append(events, name)
+	}
+	app.Startup(context.Background())
+	defer app.Shutdown(context.Background())
+
+	// Другая копия приложения переименовала сборку, время которой пересчитывается
+	// при изменении детали: перезаписывать ее нельзя
+	renamed := models.Product{ID: 2, Name: "Сборка другой копии", ProcessingTime: 2, TimeCalculation: "#1*2", Version: 2}
+	if err := other.Update(renamed, 1); err != nil {
+		t.Fatalf("Update() другой копией error = %v", err)
+	}
+
+	if err := app.UpdateProduct(1, 1, "Деталь", "3"); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("UpdateProduct() error = %v, want %v", err, storage.ErrConflict)
+	}
+	stored, err := other.Get(2)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if stored.Name != renamed.Name {
+		t.Errorf("Сборка в базе = %+v, want изменение другой копии", stored)
+	}
+	if got := app.GetProducts()[1]; got.Name != renamed.Name {
+		t.Errorf("После конфликта сборка = %+v, want перечитанная из базы", got)
+	}
+	if want := []string{EventProductsReloaded}; !reflect.DeepEqual(events, want) {
+		t.Errorf("События = %v, want %v", events, want)
+	}
+}
+
+func TestApp_ReadOnlyWhenLocked(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+
+	owner := storage.NewExcelStorage().WithFilename(filename).WithLock()
+	defer owner.Close()
+	if _, err := owner.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := owner.Save(models.Products{{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1"}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	secondStorage := storage.NewExcelStorage().WithFilename(filename).WithLock()
+	defer secondStorage.Close()
+	app := NewApp(secondStorage)
+	if err := app.products.Write(app.reload); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+
+	// Данные доступны для просмотра, но изменения отклоняются
+	if products := app.GetProducts(); len(products) != 1 {
+		t.Errorf("GetProducts() = %v, want 1 продукт", products)
+	}
+	if !app.IsReadOnly() {
+		t.Errorf("IsReadOnly() = false для базы, заблокированной другой копией")
+	}
+	if err := app.AddProduct("Продукт 2", "2"); !errors.Is(err, storage.ErrLocked) || !errors.Is(err, ErrReadOnly) {
+		t.Errorf("AddProduct() error = %v, want %v и %v", err, ErrReadOnly, storage.ErrLocked)
+	}
+	if err := app.DeleteProducts(versionsOf(app, 1)); !errors.Is(err, storage.ErrLocked) {
+		t.Errorf("DeleteProducts() error = %v, want %v", err, storage.ErrLocked)
+	}
+	if products := app.GetProducts(); len(products) != 1 {
+		t.Errorf("Отклоненные изменения изменили список: %v", products)
+	}
+
+	// Когда первая копия закрывается, блокировка берется при следующей загрузке
+	if err := owner.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := app.products.Write(app.reload); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	if app.IsReadOnly() {
+		t.Errorf("IsReadOnly() = true после снятия блокировки")
+	}
+	if err := app.AddProduct("Продукт 2", "2"); err != nil {
+		t.Errorf("AddProduct() после снятия блокировки error = %v", err)
+	}
+}
+
+// lockingMockStorage хранилище, блокировку которого может забрать другая копия
+type lockingMockStorage struct {
+	*MockStorage
+	lockErr error
+}
+
+func (ls *lockingMockStorage) LockError() error {
+	return ls.lockErr
+}
+
+func TestApp_LockLost(t *testing.T) {
+	mockStorage := &lockingMockStorage{MockStorage: NewMockStorage(nil)}
+	app := NewApp(mockStorage)
+	var events []string
+	app.emit = func(_ context.Context, name string, _ ...interface{}) {
+		events = append(events, name)
+	}
+	if err := app.products.Write(app.reload); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+
+	// Пока блокировка удерживается, событий нет
+	app.refreshLock()
+	if app.IsReadOnly() || len(events) != 0 {
+		t.Fatalf("IsReadOnly() = %v, события %v, want запись разрешена без событий", app.IsReadOnly(), events)
+	}
+
+	mockStorage.lockErr = &storage.LockedError{Holder: storage.LockInfo{PID: 2, Host: "rival-host"}}
+	app.refreshLock()
+	app.refreshLock()
+	if !reflect.DeepEqual(events, []string{EventLockLost}) {
+		t.Errorf("События = %v, want одно %s", events, EventLockLost)
+	}
+	if err := app.AddProduct("Продукт 1", "1"); !errors.Is(err, storage.ErrLocked) || !errors.Is(err, ErrReadOnly) {
+		t.Errorf("AddProduct() после потери блокировки error = %v, want %v", err, storage.ErrLocked)
+	}
+}
+
+func TestApp_ReadOnlyAfterLoadError(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	broken := []byte("не Excel файл")
+	if err := os.WriteFile(filename, broken, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	excelStorage := storage.NewExcelStorage().WithFilename(filename)
+	t.Cleanup(func() { excelStorage.Close() })
+	app := NewApp(excelStorage)
+	app.emit = func(context.Context, string, ...interface{}) {}
+	app.Startup(context.Background())
+	defer app.Shutdown(context.Background())
+
+	// Файл, который не удалось прочитать, не перезаписывается пустым списком
+	if got := app.GetLoadError(); got == "" {
+		t.Errorf("GetLoadError() = пустая строка после ошибки загрузки")
+	}
+	if !app.IsReadOnly() {
+		t.Errorf("IsReadOnly() = false после ошибки загрузки")
+	}
+	if err := app.AddProduct("Продукт 1", "1"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("AddProduct() error = %v, want %v", err, ErrReadOnly)
+	}
+	if data, err := os.ReadFile(filename); err != nil || !reflect.DeepEqual(data, broken) {
+		t.Errorf("Файл после ошибки загрузки = %q, %v, want прежнее содержимое", data, err)
+	}
+	if err := app.ReloadDatabase(); err == nil {
+		t.Errorf("ReloadDatabase() поврежденного файла error = nil")
+	}
+
+	// После исправления файла база перечитывается и изменения разрешены
+	if err := storage.NewExcelStorage().WithFilename(filename + ".new").Save(models.Products{{ID: 1, Name: "Вал"}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := os.Rename(filename+".new", filename); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if err := app.ReloadDatabase(); err != nil {
+		t.Fatalf("ReloadDatabase() error = %v", err)
+	}
+	if got := app.GetLoadError(); got != "" || app.IsReadOnly() {
+		t.Errorf("GetLoadError() = %q, IsReadOnly() = %v после перечтения", got, app.IsReadOnly())
+	}
+	if err := app.AddProduct("Продукт 2", "2"); err != nil {
+		t.Errorf("AddProduct() после перечтения error = %v", err)
+	}
+}
+
+func TestApp_ReadOnlyMode(t *testing.T) {
+	initialProducts := models.Products{
+		{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1"},
+	}
+	mockStorage := NewMockStorage(initialProducts)
+	mockStorage.saveFunc = func(models.Products) error {
+		t.Errorf("Save() вызван в режиме просмотра")
+		return nil
+	}
+	app := NewApp(mockStorage).WithReadOnly(true)
+	app.Startup(context.Background())
+
+	if !app.IsReadOnly() {
+		t.Fatalf("IsReadOnly() = false в режиме просмотра")
+	}
+
+	mutations := map[string]func() error{
+		"AddProduct":     func() error { return app.AddProduct("Продукт 2", "2") },
+		"UpdateProduct":  func() error { return app.UpdateProduct(1, 0, "Продукт 1", "3") },
+		"DeleteProduct":  func() error { return app.DeleteProduct(1, 0) },
+		"DeleteProducts": func() error { return app.DeleteProducts(versionsOf(app, 1)) },
+		"AddConstant":    func() error { return app.AddConstant("setup", 1) },
+	}
+	for name, mutate := range mutations {
+		err := mutate()
+		var readOnlyErr *ReadOnlyError
+		if !errors.As(err, &readOnlyErr) || !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s() error = %v, want *ReadOnlyError", name, err)
+		}
+	}
+
+	// Просмотр и проверка формул работают
+	if products := app.GetProducts(); !reflect.DeepEqual(products, []models.Product(initialProducts)) {
+		t.Errorf("GetProducts() = %v, want %v", products, initialProducts)
+	}
+	if result := app.ValidateFormula("1 + 1"); !result.Valid {
+		t.Errorf("ValidateFormula() в режиме просмотра = %+v", result)
+	}
+}
+
+func TestApp_SwitchDatabase(t *testing.T) {
+	app, firstFile, _ := newExcelApp(t, models.Products{
+		{ID: 1, Name: "Цех 1", ProcessingTime: 1, TimeCalculation: "1"},
+	})
+	settingsFile := filepath.Join(t.TempDir(), "settings.json")
+	app.WithSettings(settingsFile, config.Settings{DatabasePath: firstFile})
+	if err := app.AddProduct("Продукт цеха 1", "2"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+
+	// Новая база создается при открытии, история прежней базы не переносится
+	secondFile := filepath.Join(t.TempDir(), "workshop2.db")
+	if err := app.switchDatabase(secondFile); err != nil {
+		t.Fatalf("switchDatabase() error = %v", err)
+	}
+	if products := app.GetProducts(); len(products) != 0 {
+		t.Errorf("GetProducts() после открытия новой базы = %v, want пустой список", products)
+	}
+	if state := app.GetHistoryState(); state.CanUndo {
+		t.Errorf("GetHistoryState() = %+v, история прежней базы не очищена", state)
+	}
+	if got := app.GetDatabasePath(); got != secondFile {
+		t.Errorf("GetDatabasePath() = %q, want %q", got, secondFile)
+	}
+	if err := app.AddProduct("Продукт цеха 2", "3"); err != nil {
+		t.Fatalf("AddProduct() error = %v", err)
+	}
+
+	// Выбранная база запоминается в настройках
+	settings, err := config.Load(settingsFile)
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+	if settings.DatabasePath != secondFile {
+		t.Errorf("настройки DatabasePath = %q, want %q", settings.DatabasePath, secondFile)
+	}
+
+	// Повторное открытие текущей базы ничего не меняет и не блокирует запись
+	if err := app.switchDatabase(secondFile); err != nil {
+		t.Fatalf("switchDatabase() текущей базы error = %v", err)
+	}
+	if err := app.AddProduct("Продукт цеха 2", "5"); err != nil {
+		t.Fatalf("AddProduct() после повторного открытия error = %v", err)
+	}
+	if products := app.GetProducts(); len(products) != 2 {
+		t.Errorf("GetProducts() после повторного открытия = %v, want 2 продукта", products)
+	}
+	if err := app.DeleteProduct(2, app.GetProducts()[1].Version); err != nil {
+		t.Fatalf("DeleteProduct() error = %v", err)
+	}
+
+	// Возврат к первой базе показывает ее данные
+	if err := app.switchDatabase(firstFile); err != nil {
+		t.Fatalf("switchDatabase() error = %v", err)
+	}
+	if products := app.GetProducts(); len(products) != 2 || products[1].Name != "Продукт цеха 1" {
+		t.Errorf("GetProducts() после возврата = %v, want продукты первой базы", products)
+	}
+
+	// Если базу открыть не удалось, остается прежняя
+	broken := filepath.Join(t.TempDir(), "missing", "database.db")
+	if err := app.switchDatabase(broken); err == nil {
+		t.Fatalf("switchDatabase() несуществующего каталога error = nil")
+	}
+	if got := app.GetDatabasePath(); got != firstFile {
+		t.Errorf("GetDatabasePath() после ошибки = %q, want %q", got, firstFile)
+	}
+	if err := app.AddProduct("Продукт цеха 1", "4"); err != nil {
+		t.Errorf("AddProduct() после ошибки открытия error = %v", err)
+	}
+
+	// Список недавних баз начинается с последней открытой
+	if got, want := app.GetRecentDatabases(), []string{firstFile, secondFile}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRecentDatabases() = %v, want %v", got, want)
+	}
+	if err := app.SwitchDatabase(secondFile); err != nil {
+		t.Fatalf("SwitchDatabase() error = %v", err)
+	}
+	if products := app.GetProducts(); len(products) != 1 || products[0].Name != "Продукт цеха 2" {
+		t.Errorf("GetProducts() после переключения = %v, want продукты второй базы", products)
+	}
+
+	// Удаленный файл пропадает из списка недавних, открытая база не меняется
+	removed := filepath.Join(t.TempDir(), "removed.xlsx")
+	if err := app.switchDatabase(removed); err != nil {
+		t.Fatalf("switchDatabase() error = %v", err)
+	}
+	if err := app.switchDatabase(secondFile); err != nil {
+		t.Fatalf("switchDatabase() error = %v", err)
+	}
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := app.SwitchDatabase(removed); err == nil {
+		t.Errorf("SwitchDatabase() удаленного файла error = nil")
+	}
+	if got, want := app.GetRecentDatabases(), []string{secondFile, firstFile}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRecentDatabases() = %v, want %v", got, want)
+	}
+	if got := app.GetDatabasePath(); got != secondFile {
+		t.Errorf("GetDatabasePath() = %q, want %q", got, secondFile)
+	}
+	if settings, err := config.Load(settingsFile); err != nil || !reflect.DeepEqual(settings.RecentDatabases, []string{secondFile, firstFile}) {
+		t.Errorf("config.Load() = %+v, %v, want сохраненный список недавних", settings, err)
+	}
+	app.Shutdown(context.Background())
+}
+
+func TestApp_GetSettingsError(t *testing.T) {
+	app := NewApp(NewMockStorage(nil)).WithSettings("", config.Default())
+	if got := app.GetSettingsError(); got != "" {
+		t.Errorf("GetSettingsError() = %q, want пустую строку", got)
+	}
+	app.WithSettingsError(fmt.Errorf("%w: settings.json", config.ErrInvalid))
+	if got := app.GetSettingsError(); !strings.Contains(got, config.ErrInvalid.Error()) {
+		t.Errorf("GetSettingsError() = %q, want описание ошибки настроек", got)
+	}
+}
+
+func TestApp_Settings(t *testing.T) {
+	settingsFile := filepath.Join(t.TempDir(), "settings.json")
+	initial := config.Default()
+	initial.UseDatabase("/data/database.xlsx")
+	app := NewApp(NewMockStorage(nil)).WithSettings(settingsFile, initial)
+
+	// Путь к базе и список недавних не меняются через UpdateSettings
+	update := app.GetSettings()
+	update.DatabasePath = "/data/other.xlsx"
+	update.RecentDatabases = nil
+	update.ReadOnly = true
+	update.View = config.View{SortDirection: config.SortDesc, SearchQuery: "вал", FilterBySelected: true}
+	if err := app.UpdateSettings(update); err != nil {
+		t.Fatalf("UpdateSettings() error = %v", err)
+	}
+
+	want := initial
+	want.ReadOnly = true
+	want.View = update.View
+	if got := app.GetSettings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSettings() = %+v, want %+v", got, want)
+	}
+	if app.IsReadOnly() {
+		t.Errorf("IsReadOnly() = true, режим просмотра применяется при следующем запуске")
+	}
+
+	// Некорректные значения заменяются значениями по умолчанию
+	update.View.SortDirection = "up"
+	if err := app.UpdateSettings(update); err != nil {
+		t.Fatalf("UpdateSettings() error = %v", err)
+	}
+	if got := app.GetSettings().View.SortDirection; got != config.SortAsc {
+		t.Errorf("SortDirection = %q, want %q", got, config.SortAsc)
+	}
+
+	// У развернутого окна запоминается только признак, размер остается прежним
+	window := config.Window{Width: 1200, Height: 900, X: 100, Y: 50}
+	app.rememberWindow(window)
+	app.rememberWindow(config.Window{Width: 1920, Height: 1080, Maximised: true})
+	window.Maximised = true
+	if got := app.GetSettings().Window; got != window {
+		t.Errorf("Window = %+v, want %+v", got, window)
+	}
+
+	// Слишком маленькое окно заменяется размером по умолчанию
+	app.rememberWindow(config.Window{Width: 10, Height: 10, X: 100, Y: 50})
+	if got := app.GetSettings().Window; got != config.Default().Window {
+		t.Errorf("Window = %+v, want %+v", got, config.Default().Window)
+	}
+
+	saved, err := config.Load(settingsFile)
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+	if got := app.GetSettings(); !reflect.DeepEqual(saved, got) {
+		t.Errorf("config.Load() = %+v, want %+v", saved, got)
+	}
+
+	// Копия настроек не изменяет настройки приложения
+	app.GetSettings().RecentDatabases[0] = "/changed.xlsx"
+	if got := app.GetSettings().RecentDatabases[0]; got != "/data/database.xlsx" {
+		t.Errorf("RecentDatabases[0] = %q после изменения копии", got)
+	}
+}
+
+func TestApp_SetShiftHours(t *testing.T) {
+	settingsFile := filepath.Join(t.TempDir(), "settings.json")
+	initial := config.Default()
+	initial.ShiftHours = 12
+	mockStorage := NewMockStorage(models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 6, TimeCalculation: "0,5 смены"},
+		{ID: 2, Name: "Втулка", ProcessingTime: 2, TimeCalculation: "2"},
+	})
+	var savedProducts models.Products
+	mockStorage.saveFunc = func(products models.Products) error {
+		savedProducts = products
+		return nil
+	}
+	app := NewApp(mockStorage).WithSettings(settingsFile, initial)
+	app.Startup(context.Background())
+
+	// Длительность смены из настроек применяется к формулам
+	if result := app.ValidateFormula("1 смена"); result.ProcessingTime != 12 {
+		t.Fatalf("ValidateFormula(\"1 смена\") после запуска = %+v, want 12", result)
+	}
+
+	// Изменение длительности пересчитывает и сохраняет время продуктов
+	if err := app.SetShiftHours(8); err != nil {
+		t.Fatalf("SetShiftHours(8) error = %v", err)
+	}
+	if len(savedProducts) != 2 || savedProducts[0].ProcessingTime != 4 || savedProducts[1].ProcessingTime != 2 {
+		t.Errorf("Сохраненные продукты = %+v, want время 4 и 2", savedProducts)
+	}
+	if saved, err := config.Load(settingsFile); err != nil || saved.ShiftHours != 8 {
+		t.Errorf("config.Load() = %+v, %v, want длительность смены 8", saved, err)
+	}
+
+	if err := app.SetShiftHours(0); err == nil {
+		t.Errorf("SetShiftHours(0) должен вернуть ошибку")
+	}
+
+	// При ошибке сохранения продуктов длительность смены остается прежней
+	saveErr := errors.New("диск заполнен")
+	mockStorage.saveFunc = func(models.Products) error { return saveErr }
+	if err := app.SetShiftHours(10); !errors.Is(err, saveErr) {
+		t.Fatalf("SetShiftHours(10) error = %v, want %v", err, saveErr)
+	}
+	if result := app.ValidateFormula("1 смена"); result.ProcessingTime != 8 {
+		t.Errorf("ValidateFormula(\"1 смена\") после ошибки = %+v, want 8", result)
+	}
+	if got := app.GetSettings().ShiftHours; got != 8 {
+		t.Errorf("GetSettings().ShiftHours = %v, want %v", got, 8.0)
+	}
+	if saved, err := config.Load(settingsFile); err != nil || saved.ShiftHours != 8 {
+		t.Errorf("config.Load() = %+v, %v, want длительность смены 8", saved, err)
+	}
+	if got := app.GetProducts()[0].ProcessingTime; got != 4 {
+		t.Errorf("Время обработки после ошибки = %v, want %v", got, 4.0)
+	}
+}
+
+func TestApp_Quarantine(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	file := excelize.NewFile()
+	rows := [][]interface{}{
+		{"ID", "Наименование", "Время обработки в часах", "Расчет времени"},
+		{1, "Вал", 1, "1"},
+		{"x2", "Корпус", 2, "2"},
+		{1, "Дубликат", 3, "3"},
+	}
+	for i, row := range rows {
+		if err := file.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("SetSheetRow() error = %v", err)
+		}
+	}
+	if err := file.SaveAs(filename); err != nil {
+		t.Fatalf("SaveAs() error = %v", err)
+	}
+	file.Close()
+
+	excelStorage := storage.NewExcelStorage().WithFilename(filename)
+	defer excelStorage.Close()
+	app := NewApp(excelStorage)
+	app.emit = func(context.Context, string, ...interface{}) {}
+	if err := app.products.Write(app.reload); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+
+	report := app.GetLoadReport()
+	if len(report.Quarantine) != 2 || report.Quarantine[0].Key != "Sheet1!3" || report.Quarantine[1].Key != "Sheet1!4" {
+		t.Fatalf("GetLoadReport().Quarantine = %+v, want строки 3 и 4", report.Quarantine)
+	}
+
+	// Строка с ошибкой в формуле остается в карантине
+	if err := app.ImportQuarantined("Sheet1!3", "Корпус", "2+"); !errors.Is(err, ErrInvalidFormula) {
+		t.Errorf("ImportQuarantined() с ошибкой формулы error = %v, want %v", err, ErrInvalidFormula)
+	}
+	if err := app.ImportQuarantined("Sheet1!3", " ", "2"); err == nil {
+		t.Errorf("ImportQuarantined() без наименования error = nil")
+	}
+	if err := app.ImportQuarantined("Sheet1!9", "Корпус", "2"); err == nil {
+		t.Errorf("ImportQuarantined() несуществующей строки error = nil")
+	}
+	if got := len(app.GetLoadReport().Quarantine); got != 2 {
+		t.Fatalf("len(Quarantine) = %d после ошибок, want 2", got)
+	}
+
+	if err := app.ImportQuarantined("Sheet1!3", "Корпус", "#1*2"); err != nil {
+		t.Fatalf("ImportQuarantined() error = %v", err)
+	}
+	if err := app.DiscardQuarantined("Sheet1!4"); err != nil {
+		t.Fatalf("DiscardQuarantined() error = %v", err)
+	}
+	if got := app.GetLoadReport().Quarantine; len(got) != 0 {
+		t.Errorf("Quarantine = %+v, want пустой", got)
+	}
+
+	// Импортированная строка получила новый ID, карантин в файле очищен
+	reloaded := storage.NewExcelStorage().WithFilename(filename)
+	defer reloaded.Close()
+	products, err := reloaded.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 1, TimeCalculation: "1"},
+		{ID: 2, Name: "Корпус", ProcessingTime: 2, TimeCalculation: "#1*2"},
+	}
+	checkProductsEqual(t, products, want, "Load() после импорта")
+	if got := reloaded.LoadReport().Quarantine; len(got) != 0 {
+		t.Errorf("LoadReport().Quarantine после загрузки = %+v, want пустой", got)
+	}
+
+	// Хранилище без карантина возвращает пустой отчет
+	mock := NewApp(NewMockStorage(nil))
+	if report := mock.GetLoadReport(); report.Issues == nil || report.Quarantine == nil || len(report.Quarantine) != 0 {
+		t.Errorf("GetLoadReport() = %+v, want пустой отчет", report)
+	}
+	if err := mock.DiscardQuarantined("Sheet1!2"); err == nil {
+		t.Errorf("DiscardQuarantined() без карантина error = nil")
+	}
+}
+
+func TestApp_StartupChecksTimes(t *testing.T) {
+	mockStorage := NewMockStorage(models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 1, TimeCalculation: "1,5", Version: 2},
+		{ID: 2, Name: "Корпус", ProcessingTime: 2, TimeCalculation: "2", Version: 1},
+	})
+	mockStorage.constants = models.Constants{{Name: "setup", Value: 0.5}}
+	app := NewApp(mockStorage)
+	app.Startup(context.Background())
+
+	// При запуске время проверяется по формулам так же, как при перечитывании файла
+	wantMismatches := []TimeMismatch{
+		{ID: 1, Name: "Вал", TimeCalculation: "1,5", StoredTime: 1, CalculatedTime: 1.5},
+	}
+	if got := app.GetTimeMismatches(); !reflect.DeepEqual(got, wantMismatches) {
+		t.Errorf("GetTimeMismatches() после запуска = %+v, want %+v", got, wantMismatches)
+	}
+	if got := app.GetProducts()[0].ProcessingTime; got != 1.5 {
+		t.Errorf("Время обработки после запуска = %v, want %v", got, 1.5)
+	}
+	if constants := app.GetConstants(); len(constants) != 1 || constants[0].Name != "setup" {
+		t.Errorf("GetConstants() после запуска = %v, want константу setup", constants)
+	}
+}
+
+func TestApp_TimeMismatches(t *testing.T) {
+	app, filename, _ := newExcelApp(t, models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 1, TimeCalculation: "1,5", Version: 2},
+		{ID: 2, Name: "Сборка", ProcessingTime: 3, TimeCalculation: "#1*2", Version: 1},
+		{ID: 3, Name: "Корпус", ProcessingTime: 2, TimeCalculation: "2", Version: 1},
+		{ID: 4, Name: "Без формулы", ProcessingTime: 5, Version: 1},
+		{ID: 5, Name: "С ошибкой", ProcessingTime: 4, TimeCalculation: "2+", Version: 1},
+		{ID: 6, Name: "Ссылка", ProcessingTime: 1, TimeCalculation: "#99", Version: 1},
+		{ID: 7, Name: "Цикл", ProcessingTime: 2, TimeCalculation: "#7 + 1", Version: 1},
+	})
+
+	// Время пересчитывается по формулам, а время без формулы или с ошибкой остается прежним
+	want := map[int]float64{1: 1.5, 2: 3, 3: 2, 4: 5, 5: 4, 6: 1, 7: 2}
+	for _, product := range app.GetProducts() {
+		if product.ProcessingTime != want[product.ID] {
+			t.Errorf("продукт #%d ProcessingTime = %v, want %v", product.ID, product.ProcessingTime, want[product.ID])
+		}
+	}
+
+	// Продукты, формулу которых не удалось вычислить, попадают в отчет с ошибкой
+	wantMismatches := []TimeMismatch{
+		{ID: 1, Name: "Вал", TimeCalculation: "1,5", StoredTime: 1, CalculatedTime: 1.5},
+		{ID: 5, Name: "С ошибкой", TimeCalculation: "2+", StoredTime: 4, Error: "неожиданный конец формулы"},
+		{ID: 6, Name: "Ссылка", TimeCalculation: "#99", StoredTime: 1, Error: "продукт с ID 99 не найден"},
+		{ID: 7, Name: "Цикл", TimeCalculation: "#7 + 1", StoredTime: 2, Error: "циклическая ссылка"},
+	}
+	got := app.GetTimeMismatches()
+	if len(got) != len(wantMismatches) {
+		t.Fatalf("GetTimeMismatches() = %+v, want %+v", got, wantMismatches)
+	}
+	for i, mismatch := range got {
+		if !strings.Contains(mismatch.Error, wantMismatches[i].Error) || (mismatch.Error == "") != (wantMismatches[i].Error == "") {
+			t.Errorf("GetTimeMismatches()[%d].Error = %q, want содержащую %q", i, mismatch.Error, wantMismatches[i].Error)
+		}
+		mismatch.Error = wantMismatches[i].Error
+		if mismatch != wantMismatches[i] {
+			t.Errorf("GetTimeMismatches()[%d] = %+v, want %+v", i, mismatch, wantMismatches[i])
+		}
+	}
+
+	// Исправляется только время, ошибки в формулах остаются в отчете
+	if err := app.FixTimeMismatches(); err != nil {
+		t.Fatalf("FixTimeMismatches() error = %v", err)
+	}
+	if got := app.GetTimeMismatches(); len(got) != 3 || got[0].ID != 5 {
+		t.Errorf("GetTimeMismatches() после исправления = %+v, want только ошибки в формулах", got)
+	}
+
+	// Исправленное время записано в файл, версия не изменилась
+	saved := storage.NewExcelStorage().WithFilename(filename)
+	defer saved.Close()
+	products, err := saved.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if products[0].ProcessingTime != 1.5 || products[0].Version != 2 {
+		t.Errorf("Load() продукт #1 = %+v, want время 1.5 и версию 2", products[0])
+	}
+	if err := app.products.Write(app.reload); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	if got := app.GetTimeMismatches(); len(got) != 3 {
+		t.Errorf("GetTimeMismatches() после перезагрузки = %+v, want только ошибки в формулах", got)
+	}
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/storage"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/utils"
+)
+
+// appDir каталог приложения внутри каталога настроек пользователя
+const appDir = "go-reg-wails"
+
+// fileName имя файла настроек
+const fileName = "settings.json"
+
+// ErrInvalid возвращается Load, если файл настроек поврежден и его нельзя разобрать
+var ErrInvalid = errors.New("файл настроек поврежден")
+
+// RecentLimit количество баз данных в списке недавно открытых
+const RecentLimit = 10
+
+// Размеры окна по умолчанию и минимальные размеры окна
+const (
+	DefaultWindowWidth  = 800
+	DefaultWindowHeight = 650
+	MinWindowWidth      = 700
+	MinWindowHeight     = 500
+)
+
+// DefaultAPIAddress адрес HTTP API по умолчанию: доступен только с этого компьютера
+const DefaultAPIAddress = "127.0.0.1:8765"
+
+// Направления сортировки таблицы по ID
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// Settings настройки приложения, сохраняемые между запусками
+type Settings struct {
+	// DatabasePath путь к последней открытой базе данных
+	DatabasePath string `json:"databasePath"`
+	// RecentDatabases недавно открытые базы данных, начиная с последней
+	RecentDatabases []string `json:"recentDatabases"`
+	// ReadOnly открывать базы данных в режиме просмотра
+	ReadOnly bool `json:"readOnly"`
+	// ShiftHours длительность смены в часах для единицы "смена" в формулах
+	ShiftHours float64 `json:"shiftHours"`
+	Window     Window  `json:"window"`
+	View       View    `json:"view"`
+	API        API     `json:"api"`
+	Excel      Excel   `json:"excel"`
+	Backup     Backup  `json:"backup"`
+}
+
+// Backup политика хранения резервных копий Excel файлов
+type Backup struct {
+	// KeepLast сколько последних копий хранить всегда
+	KeepLast int `json:"keepLast"`
+	// KeepDays за сколько последних дней хранить по одной, самой поздней, копии в день
+	KeepDays int `json:"keepDays"`
+}
+
+// Excel настройки чтения Excel файлов, созданных другими программами
+type Excel struct {
+	// Sheet имя листа с продуктами; пустое имя означает лист Sheet1
+	// или первый лист книги
+	Sheet string `json:"sheet"`
+	// ColumnAliases дополнительные заголовки колонок по полям продукта:
+	// id, name, processingTime, timeCalculation, version, updatedAt
+	ColumnAliases map[string][]string `json:"columnAliases"`
+}
+
+// API настройки встроенного HTTP API для запросов к реестру из других программ
+type API struct {
+	Enabled bool `json:"enabled"`
+	// Address адрес и порт, на которых принимаются запросы
+	Address string `json:"address"`
+	// Token ключ доступа, который передается в заголовке Authorization: Bearer;
+	// если ключ не задан, он создается при включении API
+	Token string `json:"token"`
+}
+
+// Window положение и размеры окна приложения при последнем закрытии
+type Window struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// X и Y положение окна; нулевое положение означает окно по центру экрана
+	X         int  `json:"x"`
+	Y         int  `json:"y"`
+	Maximised bool `json:"maximised"`
+}
+
+// View настройки отображения таблицы продуктов
+type View struct {
+	// SortDirection направление сортировки по ID: SortAsc или SortDesc
+	SortDirection string `json:"sortDirection"`
+	// SearchQuery последний поисковый запрос, восстанавливается при запуске
+	SearchQuery string `json:"searchQuery"`
+	// FilterBySelected показывать только выбранные продукты
+	FilterBySelected bool `json:"filterBySelected"`
+}
+
+// Default возвращает настройки по умолчанию
+func Default() Settings {
+	return Settings{
+		ShiftHours: utils.DefaultShiftHours,
+		Window:     Window{Width: DefaultWindowWidth, Height: DefaultWindowHeight},
+		View:       View{SortDirection: SortAsc},
+		API:        API{Address: DefaultAPIAddress},
+		Backup: Backup{
+			KeepLast: storage.DefaultBackupPolicy.KeepLast,
+			KeepDays: storage.DefaultBackupPolicy.KeepDays,
+		},
+	}
+}
+
+// Normalize заменяет некорректные значения, например исправленные вручную
+// в файле настроек, значениями по умолчанию
+func (s *Settings) Normalize() {
+	if s.Window.Width < MinWindowWidth || s.Window.Height < MinWindowHeight {
+		s.Window = Default().Window
+	}
+	if s.View.SortDirection != SortAsc && s.View.SortDirection != SortDesc {
+		s.View.SortDirection = SortAsc
+	}
+	if len(s.RecentDatabases) > RecentLimit {
+		s.RecentDatabases = s.RecentDatabases[:RecentLimit]
+	}
+	if s.API.Address == "" {
+		s.API.Address = DefaultAPIAddress
+	}
+	if utils.ValidateShiftHours(s.ShiftHours) != nil {
+		s.ShiftHours = utils.DefaultShiftHours
+	}
+	// Без последних копий каждое сохранение удаляло бы только что созданную копию
+	if s.Backup.KeepLast < 1 || s.Backup.KeepDays < 0 {
+		s.Backup = Default().Backup
+	}
+}
+
+// UseDatabase делает базу данных текущей: запоминает ее путь и переносит
+// в начало списка недавно открытых, вытесняя самые старые записи
+func (s *Settings) UseDatabase(path string) {
+	s.DatabasePath = path
+	s.RemoveRecent(path)
+	s.RecentDatabases = append([]string{path}, s.RecentDatabases...)
+	if len(s.RecentDatabases) > RecentLimit {
+		s.RecentDatabases = s.RecentDatabases[:RecentLimit]
+	}
+}
+
+// IsCurrentDatabase сообщает, является ли база данных path текущей
+func (s *Settings) IsCurrentDatabase(path string) bool {
+	return s.DatabasePath != "" && samePath(s.DatabasePath, path)
+}
+
+// RemoveRecent удаляет базу данных из списка недавно открытых
+func (s *Settings) RemoveRecent(path string) {
+	s.RecentDatabases = slices.DeleteFunc(s.RecentDatabases, func(recent string) bool {
+		return samePath(recent, path)
+	})
+}
+
+// samePath сравнивает пути к файлам с учетом регистра файловой системы
+func samePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// DefaultPath возвращает путь к файлу настроек в каталоге настроек пользователя
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("ошибка при определении каталога настроек: %w", err)
+	}
+	return filepath.Join(dir, appDir, fileName), nil
+}
+
+// Load читает настройки из файла. Если файла еще нет, возвращаются настройки по умолчанию,
+// отсутствующие в файле значения также берутся по умолчанию.
+func Load(filename string) (Settings, error) {
+	settings := Default()
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, fmt.Errorf("ошибка при чтении настроек: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return Default(), fmt.Errorf("%w: %s: %w", ErrInvalid, filename, err)
+	}
+	settings.Normalize()
+	return settings, nil
+}
+
+// MoveAside переименовывает поврежденный файл настроек в <файл>.bad, чтобы
+// сохранение настроек по умолчанию не уничтожило его, и возвращает новое имя.
+// Прежний файл .bad заменяется.
+func MoveAside(filename string) (string, error) {
+	bad := filename + ".bad"
+	if err := os.Rename(filename, bad); err != nil {
+		return "", fmt.Errorf("ошибка при переименовании поврежденного файла настроек: %w", err)
+	}
+	return bad, nil
+}
+
+// Save записывает настройки в файл, создавая каталог при необходимости.
+// Файл сначала пишется во временный и затем переименовывается,
+// чтобы сбой во время записи не испортил прежние настройки.
+func Save(filename string, settings Settings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка при подготовке настроек: %w", err)
+	}
+
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("ошибка при создании каталога настроек: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, fileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка при создании временного файла настроек: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка при записи настроек: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка при записи настроек: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("ошибка при сохранении настроек: %w", err)
+	}
+	return nil
+}
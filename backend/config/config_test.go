@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string // пустая строка означает отсутствующий файл
+		// want изменяет настройки по умолчанию до ожидаемых
+		want    func(s *Settings)
+		wantErr bool
+	}{
+		{
+			name: "файла нет",
+		},
+		{
+			name:    "путь к базе",
+			content: `{"databasePath": "/data/registry.xlsx"}`,
+			want:    func(s *Settings) { s.DatabasePath = "/data/registry.xlsx" },
+		},
+		{
+			name:    "неизвестные поля игнорируются",
+			content: `{"databasePath": "reg.db", "unknown": 1}`,
+			want:    func(s *Settings) { s.DatabasePath = "reg.db" },
+		},
+		{
+			name:    "недавние базы",
+			content: `{"databasePath": "a.xlsx", "recentDatabases": ["a.xlsx", "b.db"]}`,
+			want: func(s *Settings) {
+				s.DatabasePath = "a.xlsx"
+				s.RecentDatabases = []string{"a.xlsx", "b.db"}
+			},
+		},
+		{
+			name:    "окно и таблица",
+			content: `{"readOnly": true, "window": {"width": 1024, "height": 768, "x": 10, "y": 20, "maximised": true}, "view": {"sortDirection": "desc", "searchQuery": "вал", "filterBySelected": true}}`,
+			want: func(s *Settings) {
+				s.ReadOnly = true
+				s.Window = Window{Width: 1024, Height: 768, X: 10, Y: 20, Maximised: true}
+				s.View = View{SortDirection: SortDesc, SearchQuery: "вал", FilterBySelected: true}
+			},
+		},
+		{
+			name:    "отсутствующие значения берутся по умолчанию",
+			content: `{"window": {"width": 1024}, "view": {"searchQuery": "вал"}}`,
+			want: func(s *Settings) {
+				s.Window.Width = 1024
+				s.View.SearchQuery = "вал"
+			},
+		},
+		{
+			name:    "некорректные значения заменяются",
+			content: `{"window": {"width": 100, "height": 100, "x": 5}, "view": {"sortDirection": "up"}, "api": {"address": ""}, "shiftHours": 30}`,
+		},
+		{
+			name:    "резервные копии",
+			content: `{"backup": {"keepLast": 5, "keepDays": 0}}`,
+			want:    func(s *Settings) { s.Backup = Backup{KeepLast: 5} },
+		},
+		{
+			name:    "без последних резервных копий",
+			content: `{"backup": {"keepLast": 0, "keepDays": 7}}`,
+		},
+		{
+			name:    "длительность смены",
+			content: `{"shiftHours": 12}`,
+			want:    func(s *Settings) { s.ShiftHours = 12 },
+		},
+		{
+			name:    "HTTP API",
+			content: `{"api": {"enabled": true, "address": ":9000", "token": "secret"}}`,
+			want:    func(s *Settings) { s.API = API{Enabled: true, Address: ":9000", Token: "secret"} },
+		},
+		{
+			name:    "поврежденный файл",
+			content: `{"databasePath": `,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "settings.json")
+			if tt.content != "" {
+				if err := os.WriteFile(filename, []byte(tt.content), 0o644); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+			}
+
+			got, err := Load(filename)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalid)) {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := Default()
+			if tt.want != nil {
+				tt.want(&want)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMoveAside(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "settings.json")
+	content := []byte(`{"databasePath": `)
+	if err := os.WriteFile(filename, content, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	bad, err := MoveAside(filename)
+	if err != nil {
+		t.Fatalf("MoveAside() error = %v", err)
+	}
+	if got, err := os.ReadFile(bad); err != nil || !reflect.DeepEqual(got, content) {
+		t.Errorf("Файл %s = %q, %v, want прежнее содержимое", bad, got, err)
+	}
+	// Настройки по умолчанию сохраняются, не затрагивая поврежденный файл
+	if err := Save(filename, Default()); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got, err := Load(filename); err != nil || !reflect.DeepEqual(got, Default()) {
+		t.Errorf("Load() = %+v, %v, want настройки по умолчанию", got, err)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	// Каталог настроек создается при первом сохранении
+	filename := filepath.Join(t.TempDir(), "go-reg-wails", "settings.json")
+	settings := Default()
+	settings.UseDatabase("/data/цех 2.db")
+	settings.UseDatabase("/data/цех 1.xlsx")
+	settings.Window = Window{Width: 1200, Height: 900, X: -1600, Y: 40}
+	settings.View.SortDirection = SortDesc
+
+	if err := Save(filename, settings); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := Load(filename)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, settings) {
+		t.Errorf("Load() = %+v, want %+v", got, settings)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("после Save() в каталоге %d файлов, хотим только файл настроек", len(entries))
+	}
+}
+
+func TestSettings_UseDatabase(t *testing.T) {
+	tests := []struct {
+		name   string
+		recent []string
+		path   string
+		want   []string
+	}{
+		{
+			name: "первая база",
+			path: "a.xlsx",
+			want: []string{"a.xlsx"},
+		},
+		{
+			name:   "новая база в начале списка",
+			recent: []string{"a.xlsx", "b.db"},
+			path:   "c.xlsx",
+			want:   []string{"c.xlsx", "a.xlsx", "b.db"},
+		},
+		{
+			name:   "повторно открытая база переносится в начало",
+			recent: []string{"a.xlsx", "b.db", "c.xlsx"},
+			path:   "b.db",
+			want:   []string{"b.db", "a.xlsx", "c.xlsx"},
+		},
+		{
+			name:   "пути сравниваются после нормализации",
+			recent: []string{"a.xlsx", filepath.Join("data", "b.db")},
+			path:   filepath.Join("data", ".", "b.db"),
+			want:   []string{filepath.Join("data", ".", "b.db"), "a.xlsx"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := Settings{RecentDatabases: tt.recent}
+			settings.UseDatabase(tt.path)
+			if !reflect.DeepEqual(settings.RecentDatabases, tt.want) {
+				t.Errorf("RecentDatabases = %v, want %v", settings.RecentDatabases, tt.want)
+			}
+			if settings.DatabasePath != tt.path {
+				t.Errorf("DatabasePath = %q, want %q", settings.DatabasePath, tt.path)
+			}
+		})
+	}
+
+	// Самые старые базы вытесняются из списка
+	var settings Settings
+	for i := 0; i <= RecentLimit; i++ {
+		settings.UseDatabase(fmt.Sprintf("db%d.xlsx", i))
+	}
+	if len(settings.RecentDatabases) != RecentLimit || settings.RecentDatabases[RecentLimit-1] != "db1.xlsx" {
+		t.Errorf("RecentDatabases = %v, want %d последних баз", settings.RecentDatabases, RecentLimit)
+	}
+}
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNothingToUndo возвращается, если в истории нет изменений для отмены
+var ErrNothingToUndo = errors.New("нет изменений для отмены")
+
+// ErrNothingToRedo возвращается, если в истории нет отмененных изменений для повтора
+var ErrNothingToRedo = errors.New("нет изменений для повтора")
+
+// Entry запись истории: выполненное изменение и обратное ему действие
+type Entry struct {
+	Label string       // описание изменения для интерфейса
+	Redo  func() error // повторно выполняет изменение
+	Undo  func() error // отменяет изменение
+}
+
+// History хранит ограниченную по глубине историю изменений для отмены и повтора
+type History struct {
+	mu    sync.Mutex
+	undo  []Entry
+	redo  []Entry
+	depth int
+}
+
+// New создает историю, хранящую не более depth последних изменений
+func New(depth int) *History {
+	return &History{depth: depth}
+}
+
+// Push записывает выполненное изменение. Отмененные изменения после этого
+// повторить уже нельзя, а самые старые записи сверх глубины истории удаляются.
+func (h *History) Push(entry Entry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.undo = append(h.undo, entry)
+	if len(h.undo) > h.depth {
+		h.undo = h.undo[len(h.undo)-h.depth:]
+	}
+	h.redo = nil
+}
+
+// Undo отменяет последнее изменение. Если отмена не удалась,
+// запись остается в истории.
+func (h *History) Undo() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.undo) == 0 {
+		return ErrNothingToUndo
+	}
+	entry := h.undo[len(h.undo)-1]
+	if err := entry.Undo(); err != nil {
+		return err
+	}
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, entry)
+	return nil
+}
+
+// Redo повторяет последнее отмененное изменение
+func (h *History) Redo() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.redo) == 0 {
+		return ErrNothingToRedo
+	}
+	entry := h.redo[len(h.redo)-1]
+	if err := entry.Redo(); err != nil {
+		return err
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, entry)
+	return nil
+}
+
+// Clear очищает историю
+func (h *History) Clear() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.undo = nil
+	h.redo = nil
+}
+
+// State состояние истории для отображения в интерфейсе
+type State struct {
+	CanUndo   bool   `json:"canUndo"`
+	CanRedo   bool   `json:"canRedo"`
+	UndoLabel string `json:"undoLabel"`
+	RedoLabel string `json:"redoLabel"`
+}
+
+// State возвращает текущее состояние истории
+func (h *History) State() State {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var state State
+	if len(h.undo) > 0 {
+		state.CanUndo = true
+		state.UndoLabel = h.undo[len(h.undo)-1].Label
+	}
+	if len(h.redo) > 0 {
+		state.CanRedo = true
+		state.RedoLabel = h.redo[len(h.redo)-1].Label
+	}
+	return state
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// counterEntry возвращает запись, изменяющую счетчик на delta
+func counterEntry(counter *int, delta int) Entry {
+	*counter += delta
+	return Entry{
+		Label: "изменение",
+		Redo:  func() error { *counter += delta; return nil },
+		Undo:  func() error { *counter -= delta; return nil },
+	}
+}
+
+func TestHistory_UndoRedo(t *testing.T) {
+	h := New(10)
+	counter := 0
+	h.Push(counterEntry(&counter, 1))
+	h.Push(counterEntry(&counter, 10))
+
+	steps := []struct {
+		name string
+		do   func() error
+		want int
+	}{
+		{"Undo", h.Undo, 1},
+		{"Undo", h.Undo, 0},
+		{"Redo", h.Redo, 1},
+		{"Redo", h.Redo, 11},
+	}
+	for i, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("шаг %d: %s() error = %v", i, step.name, err)
+		}
+		if counter != step.want {
+			t.Errorf("шаг %d: после %s() counter = %d, want %d", i, step.name, counter, step.want)
+		}
+	}
+
+	if err := h.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Redo() error = %v, want %v", err, ErrNothingToRedo)
+	}
+}
+
+func TestHistory_PushClearsRedo(t *testing.T) {
+	h := New(10)
+	counter := 0
+	h.Push(counterEntry(&counter, 1))
+	if err := h.Undo(); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	h.Push(counterEntry(&counter, 5))
+
+	if state := h.State(); !state.CanUndo || state.CanRedo {
+		t.Errorf("State() = %+v, want только отмену", state)
+	}
+}
+
+func TestHistory_Depth(t *testing.T) {
+	h := New(3)
+	counter := 0
+	for i := 0; i < 5; i++ {
+		h.Push(counterEntry(&counter, 1))
+	}
+
+	undone := 0
+	for h.Undo() == nil {
+		undone++
+	}
+	if undone != 3 {
+		t.Errorf("Отменено изменений = %d, want %d", undone, 3)
+	}
+	if counter != 2 {
+		t.Errorf("counter = %d, want %d", counter, 2)
+	}
+}
+
+func TestHistory_FailedUndoKeepsEntry(t *testing.T) {
+	h := New(10)
+	failure := errors.New("сбой")
+	h.Push(Entry{
+		Label: "сбойное изменение",
+		Redo:  func() error { return nil },
+		Undo:  func() error { return failure },
+	})
+
+	if err := h.Undo(); !errors.Is(err, failure) {
+		t.Fatalf("Undo() error = %v, want %v", err, failure)
+	}
+	want := State{CanUndo: true, UndoLabel: "сбойное изменение"}
+	if state := h.State(); !reflect.DeepEqual(state, want) {
+		t.Errorf("State() = %+v, want %+v", state, want)
+	}
+
+	h.Clear()
+	if state := h.State(); state != (State{}) {
+		t.Errorf("После Clear() State() = %+v, want пустое состояние", state)
+	}
+}
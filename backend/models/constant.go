@@ -0,0 +1,50 @@
+package models
+
+// Constant представляет собой именованную константу для формул расчета времени
+type Constant struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// Constants представляет собой срез констант с методами для работы
+type Constants []Constant
+
+// Get возвращает константу по имени
+func (c Constants) Get(name string) (Constant, bool) {
+	for _, constant := range c {
+		if constant.Name == name {
+			return constant, true
+		}
+	}
+	return Constant{}, false
+}
+
+// Set добавляет константу или обновляет значение существующей
+func (c *Constants) Set(constant Constant) {
+	for i, existing := range *c {
+		if existing.Name == constant.Name {
+			(*c)[i] = constant
+			return
+		}
+	}
+	*c = append(*c, constant)
+}
+
+// Delete удаляет константу по имени
+func (c *Constants) Delete(name string) {
+	for i, constant := range *c {
+		if constant.Name == name {
+			*c = append((*c)[:i], (*c)[i+1:]...)
+			break
+		}
+	}
+}
+
+// Values возвращает значения констант по именам
+func (c Constants) Values() map[string]float64 {
+	values := make(map[string]float64, len(c))
+	for _, constant := range c {
+		values[constant.Name] = constant.Value
+	}
+	return values
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConstantsGet(t *testing.T) {
+	constants := Constants{
+		{Name: "setup_cnc", Value: 0.75},
+		{Name: "changeover", Value: 0.5},
+	}
+
+	constant, ok := constants.Get("changeover")
+	if !ok || constant.Value != 0.5 {
+		t.Errorf("Constants.Get(\"changeover\") = %v, %v, want 0.5, true", constant, ok)
+	}
+
+	if _, ok := constants.Get("unknown"); ok {
+		t.Errorf("Constants.Get(\"unknown\") должен вернуть false")
+	}
+}
+
+func TestConstantsSet(t *testing.T) {
+	constants := Constants{{Name: "setup_cnc", Value: 0.75}}
+
+	constants.Set(Constant{Name: "setup_cnc", Value: 1})
+	constants.Set(Constant{Name: "changeover", Value: 0.5})
+
+	expected := Constants{
+		{Name: "setup_cnc", Value: 1},
+		{Name: "changeover", Value: 0.5},
+	}
+	if !reflect.DeepEqual(constants, expected) {
+		t.Errorf("После Constants.Set() = %v, want %v", constants, expected)
+	}
+}
+
+func TestConstantsDelete(t *testing.T) {
+	constants := Constants{
+		{Name: "setup_cnc", Value: 0.75},
+		{Name: "changeover", Value: 0.5},
+	}
+
+	constants.Delete("setup_cnc")
+	constants.Delete("unknown")
+
+	expected := Constants{{Name: "changeover", Value: 0.5}}
+	if !reflect.DeepEqual(constants, expected) {
+		t.Errorf("После Constants.Delete() = %v, want %v", constants, expected)
+	}
+}
+
+func TestConstantsValues(t *testing.T) {
+	constants := Constants{
+		{Name: "setup_cnc", Value: 0.75},
+		{Name: "changeover", Value: 0.5},
+	}
+
+	expected := map[string]float64{"setup_cnc": 0.75, "changeover": 0.5}
+	if values := constants.Values(); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Constants.Values() = %v, want %v", values, expected)
+	}
+}
@@ -1,6 +1,10 @@
 package models
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Product представляет собой структуру продукта
 type Product struct {
@@ -8,6 +12,26 @@ type Product struct {
 	Name            string  `json:"name"`
 	ProcessingTime  float64 `json:"processingTime"`
 	TimeCalculation string  `json:"timeCalculation"`
+	// Version увеличивается при каждом изменении продукта пользователем
+	// и позволяет заметить, что продукт изменил кто-то другой
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ConflictError возвращается при изменении или удалении продукта, который после
+// получения пользователем изменили или удалили другие
+type ConflictError struct {
+	ID       int      // ID продукта
+	Expected int      // версия, которую видел пользователь
+	Current  *Product // текущее состояние продукта или nil, если продукт удален
+}
+
+// Error возвращает описание конфликта
+func (e *ConflictError) Error() string {
+	if e.Current == nil {
+		return fmt.Sprintf("продукт #%d удален другим пользователем", e.ID)
+	}
+	return fmt.Sprintf("продукт #%d изменен другим пользователем: версия %d, ожидалась %d", e.ID, e.Current.Version, e.Expected)
 }
 
 // Products представляет собой срез продуктов с методами для работы
@@ -79,6 +103,27 @@ func (p *Products) Update(product Product) {
 	}
 }
 
+// Insert вставляет продукт в указанную позицию.
+// Позиция за пределами списка означает добавление в конец.
+func (p *Products) Insert(index int, product Product) {
+	if index < 0 || index > len(*p) {
+		index = len(*p)
+	}
+	*p = append(*p, Product{})
+	copy((*p)[index+1:], (*p)[index:])
+	(*p)[index] = product
+}
+
+// IndexOf возвращает позицию продукта с указанным ID или -1
+func (p Products) IndexOf(id int) int {
+	for i, product := range p {
+		if product.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
 // GetNextID возвращает следующий доступный ID
 func (p Products) GetNextID() int {
 	maxID := 0
@@ -233,3 +233,69 @@ func TestProductsGetNextID(t *testing.T) {
 		})
 	}
 }
+
+func TestProductsInsert(t *testing.T) {
+	tests := []struct {
+		name     string
+		index    int
+		expected []int
+	}{
+		{name: "Вставка в начало", index: 0, expected: []int{9, 1, 2}},
+		{name: "Вставка в середину", index: 1, expected: []int{1, 9, 2}},
+		{name: "Вставка в конец", index: 2, expected: []int{1, 2, 9}},
+		{name: "Позиция за пределами списка", index: 10, expected: []int{1, 2, 9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := Products{{ID: 1}, {ID: 2}}
+			products.Insert(tt.index, Product{ID: 9})
+
+			var ids []int
+			for _, product := range products {
+				ids = append(ids, product.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("После Products.Insert(%d) ID = %v, want %v", tt.index, ids, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProductsIndexOf(t *testing.T) {
+	products := Products{{ID: 5}, {ID: 2}, {ID: 10}}
+
+	if index := products.IndexOf(10); index != 2 {
+		t.Errorf("Products.IndexOf(10) = %d, want %d", index, 2)
+	}
+	if index := products.IndexOf(3); index != -1 {
+		t.Errorf("Products.IndexOf(3) = %d, want %d", index, -1)
+	}
+}
+
+func TestConflictError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *ConflictError
+		expected string
+	}{
+		{
+			name:     "Продукт изменен",
+			err:      &ConflictError{ID: 3, Expected: 1, Current: &Product{ID: 3, Version: 2}},
+			expected: "продукт #3 изменен другим пользователем: версия 2, ожидалась 1",
+		},
+		{
+			name:     "Продукт удален",
+			err:      &ConflictError{ID: 3, Expected: 1},
+			expected: "продукт #3 удален другим пользователем",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.expected {
+				t.Errorf("ConflictError.Error() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"slices"
+	"sync"
+)
+
+// Repository потокобезопасный список продуктов в памяти.
+// Изменения выполняются по одному, а наружу отдаются только копии списка,
+// поэтому методы приложения можно вызывать из фронтенда параллельно.
+type Repository struct {
+	mu       sync.RWMutex
+	products Products
+}
+
+// NewRepository создает репозиторий с копией переданного списка продуктов
+func NewRepository(products Products) *Repository {
+	return &Repository{
+		products: slices.Clone(products),
+	}
+}
+
+// All возвращает копию всех продуктов
+func (r *Repository) All() Products {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Clone(r.products)
+}
+
+// Search ищет продукты по запросу и возвращает копию результата
+func (r *Repository) Search(query string) Products {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Clone(r.products.Search(query))
+}
+
+// Read вызывает fn с текущим списком под блокировкой чтения.
+// fn не должна изменять список и сохранять ссылку на него после возврата.
+func (r *Repository) Read(fn func(products Products)) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn(r.products)
+}
+
+// Write вызывает fn с исключительным доступом к списку: изменения выполняются
+// по одному, и читатели видят их только после завершения fn.
+// Изменения, сделанные fn до ошибки, не откатываются.
+func (r *Repository) Write(fn func(products *Products) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return fn(&r.products)
+}
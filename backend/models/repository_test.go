@@ -0,0 +1,74 @@
+package models
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRepository_ReturnsCopies(t *testing.T) {
+	source := Products{
+		{ID: 1, Name: "Продукт 1"},
+		{ID: 2, Name: "Продукт 2"},
+	}
+	repo := NewRepository(source)
+
+	// Изменение исходного списка и возвращенных копий не затрагивает репозиторий
+	source[0].Name = "Изменен"
+	all := repo.All()
+	all[1].Name = "Изменен"
+	found := repo.Search("")
+	found[0].Name = "Изменен"
+
+	want := Products{
+		{ID: 1, Name: "Продукт 1"},
+		{ID: 2, Name: "Продукт 2"},
+	}
+	got := repo.All()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("All()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRepository_ConcurrentWrites(t *testing.T) {
+	repo := NewRepository(nil)
+
+	const writers = 20
+	const perWriter = 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				repo.Write(func(products *Products) error {
+					*products = append(*products, Product{ID: products.GetNextID(), Name: "Продукт"})
+					return nil
+				})
+			}
+		}()
+		// Параллельное чтение, чтобы детектор гонок видел доступ к списку
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				_ = repo.All()
+				_ = repo.Search("прод")
+				repo.Read(func(products Products) { _ = len(products) })
+			}
+		}()
+	}
+	wg.Wait()
+
+	products := repo.All()
+	if len(products) != writers*perWriter {
+		t.Fatalf("Количество продуктов = %d, want %d", len(products), writers*perWriter)
+	}
+	seen := make(map[int]bool)
+	for _, product := range products {
+		if seen[product.ID] {
+			t.Errorf("ID %d встречается несколько раз", product.ID)
+		}
+		seen[product.ID] = true
+	}
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic безопасно перезаписывает файл: данные пишутся во временный
+// файл в той же директории, сбрасываются на диск и только затем переименовываются
+// поверх исходного. При сбое во время записи исходный файл остается нетронутым.
+func writeFileAtomic(filename string, write func(w io.Writer) error) (err error) {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка при создании временного файла: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	// Сохраняем права доступа исходного файла
+	mode := os.FileMode(0o644)
+	if info, statErr := os.Stat(filename); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return fmt.Errorf("ошибка при установке прав временного файла: %w", err)
+	}
+
+	if err = write(tmp); err != nil {
+		return fmt.Errorf("ошибка при записи временного файла: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("ошибка при сбросе временного файла на диск: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка при закрытии временного файла: %w", err)
+	}
+	if err = os.Rename(tmpName, filename); err != nil {
+		return fmt.Errorf("ошибка при замене файла: %w", err)
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir сбрасывает на диск запись директории, чтобы переименование пережило сбой питания.
+// Не на всех системах директорию можно открыть для синхронизации, поэтому ошибки игнорируются.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeLayout формат времени в имени файла резервной копии
+const backupTimeLayout = "20060102-150405.000"
+
+// BackupPolicy политика хранения резервных копий
+type BackupPolicy struct {
+	KeepLast int // сколько последних копий хранить всегда
+	KeepDays int // за сколько последних дней хранить по одной, самой поздней, копии в день
+}
+
+// DefaultBackupPolicy политика хранения резервных копий по умолчанию
+var DefaultBackupPolicy = BackupPolicy{KeepLast: 20, KeepDays: 30}
+
+// Backup описание резервной копии
+type Backup struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Size      int64     `json:"size"`
+}
+
+// BackupManager создает резервные копии файла базы данных в отдельной
+// директории и удаляет устаревшие по политике хранения
+type BackupManager struct {
+	dir    string
+	policy BackupPolicy
+	now    func() time.Time
+}
+
+// NewBackupManager создает менеджер резервных копий в указанной директории
+func NewBackupManager(dir string, policy BackupPolicy) *BackupManager {
+	return &BackupManager{
+		dir:    dir,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// Create копирует файл в директорию резервных копий и удаляет устаревшие копии.
+// Если исходного файла нет, копия не создается и возвращается пустой Backup.
+func (bm *BackupManager) Create(source string) (Backup, error) {
+	src, err := os.Open(source)
+	if errors.Is(err, os.ErrNotExist) {
+		return Backup{}, nil
+	}
+	if err != nil {
+		return Backup{}, fmt.Errorf("ошибка при открытии файла для резервной копии: %w", err)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return Backup{}, fmt.Errorf("ошибка при чтении файла для резервной копии: %w", err)
+	}
+
+	if err := os.MkdirAll(bm.dir, 0o755); err != nil {
+		return Backup{}, fmt.Errorf("ошибка при создании директории резервных копий: %w", err)
+	}
+
+	// Время в имени файла хранится с точностью до миллисекунд в местном часовом поясе
+	createdAt := bm.now().Local().Truncate(time.Millisecond)
+	id := backupID(source, createdAt)
+	if err := writeFileAtomic(filepath.Join(bm.dir, id), func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	}); err != nil {
+		return Backup{}, fmt.Errorf("ошибка при создании резервной копии: %w", err)
+	}
+
+	if err := bm.Prune(source); err != nil {
+		return Backup{}, err
+	}
+	return Backup{ID: id, CreatedAt: createdAt, Size: info.Size()}, nil
+}
+
+// List возвращает резервные копии файла, начиная с самой новой
+func (bm *BackupManager) List(source string) ([]Backup, error) {
+	entries, err := os.ReadDir(bm.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении директории резервных копий: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		createdAt, ok := parseBackupID(source, entry.Name())
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{ID: entry.Name(), CreatedAt: createdAt, Size: info.Size()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Path возвращает путь к файлу резервной копии по ее ID
+func (bm *BackupManager) Path(source, id string) (string, error) {
+	if _, ok := parseBackupID(source, id); !ok || filepath.Base(id) != id {
+		return "", fmt.Errorf("резервная копия %q: %w", id, ErrNotFound)
+	}
+	path := filepath.Join(bm.dir, id)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("резервная копия %q: %w", id, ErrNotFound)
+	}
+	return path, nil
+}
+
+// Prune удаляет резервные копии, не попадающие под политику хранения:
+// остаются последние KeepLast копий и по одной самой поздней копии
+// за каждый из последних KeepDays календарных дней, включая текущий
+func (bm *BackupManager) Prune(source string) error {
+	backups, err := bm.List(source)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	for i, backup := range backups {
+		if i < bm.policy.KeepLast {
+			keep[backup.ID] = true
+		}
+	}
+
+	if bm.policy.KeepDays > 0 {
+		now := bm.now().Local()
+		oldest := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1-bm.policy.KeepDays)
+		days := make(map[string]bool)
+		for _, backup := range backups {
+			day := backup.CreatedAt.Format("20060102")
+			if backup.CreatedAt.Before(oldest) || days[day] {
+				continue
+			}
+			// Копии отсортированы от новых к старым, поэтому первая за день самая поздняя
+			days[day] = true
+			keep[backup.ID] = true
+		}
+	}
+
+	for _, backup := range backups {
+		if keep[backup.ID] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(bm.dir, backup.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("ошибка при удалении резервной копии: %w", err)
+		}
+	}
+	return nil
+}
+
+// backupID формирует имя файла резервной копии: database-20250102-150405.000.xlsx
+func backupID(source string, createdAt time.Time) string {
+	ext := filepath.Ext(source)
+	stem := strings.TrimSuffix(filepath.Base(source), ext)
+	return stem + "-" + createdAt.Format(backupTimeLayout) + ext
+}
+
+// parseBackupID извлекает время создания из имени файла резервной копии
+func parseBackupID(source, id string) (time.Time, bool) {
+	ext := filepath.Ext(source)
+	stem := strings.TrimSuffix(filepath.Base(source), ext)
+	if !strings.HasPrefix(id, stem+"-") || !strings.HasSuffix(id, ext) {
+		return time.Time{}, false
+	}
+
+	stamp := strings.TrimSuffix(strings.TrimPrefix(id, stem+"-"), ext)
+	createdAt, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
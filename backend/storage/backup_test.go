@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+)
+
+func TestBackupManager_CreateAndList(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "database.xlsx")
+	manager := NewBackupManager(filepath.Join(dir, "backups"), BackupPolicy{KeepLast: 10})
+
+	// Для несуществующего файла копия не создается
+	backup, err := manager.Create(source)
+	if err != nil || backup.ID != "" {
+		t.Fatalf("Create() несуществующего файла = %v, %v, want пустую копию", backup, err)
+	}
+
+	if err := os.WriteFile(source, []byte("версия 1"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	now := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local)
+	manager.now = func() time.Time { return now }
+
+	first, err := manager.Create(source)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if first.ID != "database-20250301-100000.000.xlsx" {
+		t.Errorf("Create() ID = %q, want %q", first.ID, "database-20250301-100000.000.xlsx")
+	}
+
+	now = now.Add(time.Minute)
+	second, err := manager.Create(source)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Посторонние файлы в директории копий не учитываются
+	if err := os.WriteFile(filepath.Join(dir, "backups", "notes.txt"), nil, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	backups, err := manager.List(source)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 2 || backups[0].ID != second.ID || backups[1].ID != first.ID {
+		t.Fatalf("List() = %v, want [%s %s]", backups, second.ID, first.ID)
+	}
+	if backups[0].Size != int64(len("версия 1")) {
+		t.Errorf("List() размер копии = %d, want %d", backups[0].Size, len("версия 1"))
+	}
+
+	if _, err := manager.Path(source, first.ID); err != nil {
+		t.Errorf("Path() error = %v", err)
+	}
+	for _, id := range []string{"../database.xlsx", "database-20250101-000000.000.xlsx", "notes.txt"} {
+		if _, err := manager.Path(source, id); err == nil {
+			t.Errorf("Path(%q) должен вернуть ошибку", id)
+		}
+	}
+}
+
+func TestBackupManager_Prune(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "database.xlsx")
+	if err := os.WriteFile(source, []byte("данные"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	manager := NewBackupManager(filepath.Join(dir, "backups"), BackupPolicy{KeepLast: 2, KeepDays: 3})
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.Local)
+	var now time.Time
+	manager.now = func() time.Time { return now }
+
+	// По две копии в день в течение пяти дней
+	for day := 0; day < 5; day++ {
+		for _, hour := range []int{0, 8} {
+			now = start.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)
+			if _, err := manager.Create(source); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+	}
+
+	backups, err := manager.List(source)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var ids []string
+	for _, backup := range backups {
+		ids = append(ids, backup.ID)
+	}
+
+	// Две последние копии и по последней копии за каждый из трех последних дней: 3, 4 и 5 марта
+	expected := []string{
+		"database-20250305-170000.000.xlsx",
+		"database-20250305-090000.000.xlsx",
+		"database-20250304-170000.000.xlsx",
+		"database-20250303-170000.000.xlsx",
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("После Prune() копии = %v, want %v", ids, expected)
+	}
+}
+
+func TestExcelStorage_BackupsAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "database.xlsx")
+	storage := NewExcelStorage().WithFilename(filename).WithBackups(BackupPolicy{KeepLast: 10})
+	defer storage.Close()
+
+	original := models.Products{
+		{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1"},
+		{ID: 2, Name: "Продукт 2", ProcessingTime: 2, TimeCalculation: "2"},
+	}
+	if err := storage.Save(original); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	// Первое сохранение: файла еще не было, копировать нечего
+	if backups, _ := storage.ListBackups(); len(backups) != 0 {
+		t.Fatalf("ListBackups() = %v, want пустой список", backups)
+	}
+
+	if err := storage.Save(models.Products{}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	backups, err := storage.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("ListBackups() = %v, want одну копию", backups)
+	}
+
+	if err := storage.RestoreBackup(backups[0].ID); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	restored, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(restored, original) {
+		t.Errorf("Load() после RestoreBackup() = %v, want %v", restored, original)
+	}
+
+	// Версия до восстановления тоже сохранена в копиях
+	if backups, _ := storage.ListBackups(); len(backups) != 2 {
+		t.Errorf("ListBackups() после восстановления = %d копий, want %d", len(backups), 2)
+	}
+
+	if err := storage.RestoreBackup("unknown.xlsx"); err == nil {
+		t.Errorf("RestoreBackup() несуществующей копии должен вернуть ошибку")
+	}
+}
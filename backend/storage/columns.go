@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Поля продукта, которые ищутся в колонках листа Excel по заголовкам
+const (
+	ColumnID              = "id"
+	ColumnName            = "name"
+	ColumnProcessingTime  = "processingTime"
+	ColumnTimeCalculation = "timeCalculation"
+	ColumnVersion         = "version"
+	ColumnUpdatedAt       = "updatedAt"
+)
+
+// productFields поля продукта в порядке колонок нового файла
+var productFields = []string{
+	ColumnID,
+	ColumnName,
+	ColumnProcessingTime,
+	ColumnTimeCalculation,
+	ColumnVersion,
+	ColumnUpdatedAt,
+}
+
+// productHeaders заголовки колонок, которые записываются в новый файл
+var productHeaders = map[string]string{
+	ColumnID:              "ID",
+	ColumnName:            "Наименование",
+	ColumnProcessingTime:  "Время обработки в часах",
+	ColumnTimeCalculation: "Расчет времени",
+	ColumnVersion:         "Версия",
+	ColumnUpdatedAt:       "Изменено",
+}
+
+// ColumnAliases дополнительные заголовки колонок по полям продукта.
+// Заголовки сравниваются без учета регистра и лишних пробелов.
+type ColumnAliases map[string][]string
+
+// DefaultColumnAliases заголовки, под которыми колонки продукта
+// встречаются в файлах на русском и английском языках. Заголовки вроде
+// "№" или "Код" сюда не входят: такие колонки часто содержат порядковый
+// номер строки или артикул, а не ID, и их нужно указывать явно.
+var DefaultColumnAliases = ColumnAliases{
+	ColumnID:              {"ID", "Номер"},
+	ColumnName:            {"Наименование", "Название", "Изделие", "Name", "Product", "Title"},
+	ColumnProcessingTime:  {"Время обработки в часах", "Время обработки", "Время, ч", "Время", "Processing time", "Processing time, h", "Time", "Hours"},
+	ColumnTimeCalculation: {"Расчет времени", "Формула", "Time calculation", "Formula", "Calculation"},
+	ColumnVersion:         {"Версия", "Version"},
+	ColumnUpdatedAt:       {"Изменено", "Дата изменения", "Updated", "Updated at", "Modified"},
+}
+
+// requiredFields поля, без которых лист нельзя прочитать как список продуктов
+var requiredFields = []string{ColumnID, ColumnName}
+
+// excelColumn колонка листа продуктов
+type excelColumn struct {
+	// field поле продукта или пустая строка для дополнительной колонки,
+	// значения которой сохраняются без изменений
+	field  string
+	header string
+}
+
+// columnLayout лист с продуктами и расположение колонок на нем
+type columnLayout struct {
+	sheet   string
+	columns []excelColumn
+}
+
+// defaultLayout возвращает расположение колонок нового файла
+func defaultLayout() columnLayout {
+	var layout columnLayout
+	for _, field := range productFields {
+		layout.columns = append(layout.columns, excelColumn{field: field, header: productHeaders[field]})
+	}
+	return layout
+}
+
+// index возвращает номер колонки поля, начиная с 0, или -1, если колонки нет
+func (l columnLayout) index(field string) int {
+	for i, column := range l.columns {
+		if column.field == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// headers возвращает строку заголовков
+func (l columnLayout) headers() []interface{} {
+	headers := make([]interface{}, len(l.columns))
+	for i, column := range l.columns {
+		headers[i] = column.header
+	}
+	return headers
+}
+
+// extraHeaders возвращает заголовки дополнительных колонок
+func (l columnLayout) extraHeaders() []string {
+	var headers []string
+	for _, column := range l.columns {
+		if column.field == "" {
+			headers = append(headers, column.header)
+		}
+	}
+	return headers
+}
+
+// parseLayout определяет колонки продукта по строке заголовков header.
+// Колонки с незнакомыми заголовками и колонки без заголовка, в которых есть
+// данные, сохраняются как дополнительные; width ширина листа с учетом данных.
+// Заголовки aliases дополняют DefaultColumnAliases и имеют перед ними приоритет.
+// Если одному полю соответствуют несколько колонок, поле берется из колонки,
+// заголовок которой стоит раньше в aliases, а затем в DefaultColumnAliases;
+// при равных заголовках из левой колонки. Остальные колонки сохраняются как
+// дополнительные: лишняя колонка "Время" не должна делать файл нечитаемым.
+// Отсутствующие необязательные поля добавляются в конец, чтобы их можно было записать.
+func parseLayout(sheet string, header []string, width int, aliases ColumnAliases) (columnLayout, error) {
+	// rank порядковый номер заголовка среди заголовков поля: сначала
+	// заголовки из aliases, затем заголовки по умолчанию
+	type match struct {
+		field string
+		rank  int
+	}
+	fields := make(map[string]match)
+	for field, names := range DefaultColumnAliases {
+		for i, name := range names {
+			fields[normalizeHeader(name)] = match{field: field, rank: len(aliases[field]) + i}
+		}
+	}
+	for field, names := range aliases {
+		for i, name := range names {
+			fields[normalizeHeader(name)] = match{field: field, rank: i}
+		}
+	}
+
+	var layout columnLayout
+	// chosen колонка каждого поля, rank ранг ее заголовка
+	chosen := make(map[string]int)
+	rank := make(map[string]int)
+	for i := 0; i < max(len(header), width); i++ {
+		column := excelColumn{}
+		if i < len(header) {
+			column.header = header[i]
+		}
+		if m, ok := fields[normalizeHeader(column.header)]; ok {
+			if best, ok := rank[m.field]; !ok || m.rank < best {
+				chosen[m.field], rank[m.field] = i, m.rank
+			}
+		}
+		layout.columns = append(layout.columns, column)
+	}
+	for field, i := range chosen {
+		layout.columns[i].field = field
+	}
+
+	for _, field := range requiredFields {
+		if layout.index(field) < 0 {
+			return columnLayout{}, fmt.Errorf("на листе %s не найдена колонка %q", sheet, productHeaders[field])
+		}
+	}
+	for _, field := range productFields {
+		if layout.index(field) < 0 {
+			layout.columns = append(layout.columns, excelColumn{field: field, header: productHeaders[field]})
+		}
+	}
+	return layout, nil
+}
+
+// normalizeHeader приводит заголовок к виду для сравнения
+func normalizeHeader(header string) string {
+	return strings.ToLower(strings.Join(strings.Fields(header), " "))
+}
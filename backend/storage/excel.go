@@ -1,19 +1,73 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
 	"github.com/xuri/excelize/v2"
 )
 
-// ExcelStorage реализует интерфейс Storage для работы с Excel файлами
+// defaultProductsSheet лист с продуктами, если имя листа не задано
+const defaultProductsSheet = "Sheet1"
+
+// constantsSheet лист с именованными константами формул
+const constantsSheet = "Константы"
+
+// quarantineSheet лист со строками, которые не удалось загрузить как продукты
+const quarantineSheet = "Карантин"
+
+// productColumnCount количество колонок продукта: ID, наименование, время обработки,
+// расчет времени, версия и время изменения
+var productColumnCount = len(productFields)
+
+// backupsDir директория резервных копий рядом с файлом базы данных
+const backupsDir = "backups"
+
+// ExcelStorage реализует интерфейсы Storage, ConstantStorage, BackupStorage,
+// WatchedStorage, LockingStorage и ReportingStorage для работы с Excel файлами
 type ExcelStorage struct {
-	file     *excelize.File
-	filename string
+	file         *excelize.File
+	filename     string
+	constants    models.Constants
+	backupPolicy *BackupPolicy
+	watcher      fileWatcher
+	lock         dbLock
+	// report проблемы последней загрузки и строки карантина
+	report LoadReport
+	// sheet имя листа с продуктами, заданное через WithSheet
+	sheet   string
+	aliases ColumnAliases
+	// layout имя листа и расположение колонок, прочитанные при последней загрузке
+	layout columnLayout
+	// rows строки листа, в которых записаны продукты, по ID продукта
+	rows map[int]sheetRow
+	// readOnly запрещает Load создавать файл, которого нет
+	readOnly bool
 }
 
+// sheetRow строка листа с продуктом
+type sheetRow struct {
+	number int
+	// extras значения дополнительных колонок
+	extras []string
+}
+
+// Проверка реализации интерфейсов на этапе компиляции
+var (
+	_ ConstantStorage  = (*ExcelStorage)(nil)
+	_ BackupStorage    = (*ExcelStorage)(nil)
+	_ WatchedStorage   = (*ExcelStorage)(nil)
+	_ LockingStorage   = (*ExcelStorage)(nil)
+	_ ReportingStorage = (*ExcelStorage)(nil)
+)
+
 // NewExcelStorage создает новый экземпляр хранилища Excel
 func NewExcelStorage() *ExcelStorage {
 	return &ExcelStorage{
@@ -27,10 +81,63 @@ func (es *ExcelStorage) WithFilename(filename string) *ExcelStorage {
 	return es
 }
 
+// WithSheet задает имя листа с продуктами. Без него используется лист Sheet1,
+// а если его нет, то первый лист книги, кроме служебных.
+func (es *ExcelStorage) WithSheet(name string) *ExcelStorage {
+	es.sheet = name
+	return es
+}
+
+// WithColumnAliases добавляет заголовки, под которыми в файле могут быть
+// записаны колонки продукта, к заголовкам DefaultColumnAliases
+func (es *ExcelStorage) WithColumnAliases(aliases ColumnAliases) *ExcelStorage {
+	es.aliases = aliases
+	return es
+}
+
+// WithBackups включает резервное копирование файла перед каждым сохранением.
+// Копии хранятся в директории backups рядом с файлом базы данных.
+func (es *ExcelStorage) WithBackups(policy BackupPolicy) *ExcelStorage {
+	es.backupPolicy = &policy
+	return es
+}
+
+// WithReadOnly включает режим просмотра: если файла нет, Load возвращает
+// ошибку, а не создает новый файл
+func (es *ExcelStorage) WithReadOnly() *ExcelStorage {
+	es.readOnly = true
+	return es
+}
+
+// WithLock включает блокировку файла от записи другими копиями приложения:
+// блокировка берется при Load и снимается при Close
+func (es *ExcelStorage) WithLock() *ExcelStorage {
+	es.lock.enabled = true
+	return es
+}
+
+// LockError возвращает *LockedError, если файл удерживает другая копия приложения
+func (es *ExcelStorage) LockError() error {
+	return es.lock.writable()
+}
+
+// backups возвращает менеджер резервных копий для текущего файла
+func (es *ExcelStorage) backups() *BackupManager {
+	policy := DefaultBackupPolicy
+	if es.backupPolicy != nil {
+		policy = *es.backupPolicy
+	}
+	return NewBackupManager(filepath.Join(filepath.Dir(es.filename), backupsDir), policy)
+}
+
 // Load загружает данные из Excel файла
 func (es *ExcelStorage) Load() (models.Products, error) {
 	var products models.Products
 
+	if err := es.lock.acquire(es.filename); err != nil {
+		return products, err
+	}
+
 	// Закрываем предыдущий файл если он был открыт
 	if es.file != nil {
 		if err := es.file.Close(); err != nil {
@@ -38,119 +145,752 @@ func (es *ExcelStorage) Load() (models.Products, error) {
 		}
 	}
 
+	// Запоминаем файл до чтения: изменения, сделанные после, будут замечены
+	if err := es.watcher.remember(es.filename); err != nil {
+		return products, err
+	}
+
 	// Попытка открыть существующий файл
 	var err error
 	es.file, err = excelize.OpenFile(es.filename)
-	if err != nil {
+	if errors.Is(err, os.ErrNotExist) && !es.readOnly {
 		// Если файл не существует, создаем новый
-		es.file = excelize.NewFile()
-		// Создаем заголовки
-		if err := es.file.SetCellValue("Sheet1", "A1", "ID"); err != nil {
-			return products, fmt.Errorf("ошибка при установке заголовка ID: %w", err)
-		}
-		if err := es.file.SetCellValue("Sheet1", "B1", "Наименование"); err != nil {
-			return products, fmt.Errorf("ошибка при установке заголовка Наименование: %w", err)
+		if err := es.newWorkbook(); err != nil {
+			return products, err
 		}
-		if err := es.file.SetCellValue("Sheet1", "C1", "Время обработки в часах"); err != nil {
-			return products, fmt.Errorf("ошибка при установке заголовка Время обработки: %w", err)
+		es.constants = nil
+		es.report = LoadReport{}
+		if err := es.writeProducts(nil); err != nil {
+			return products, err
 		}
-		if err := es.file.SetCellValue("Sheet1", "D1", "Расчет времени"); err != nil {
-			return products, fmt.Errorf("ошибка при установке заголовка Расчет времени: %w", err)
-		}
-		return products, es.file.SaveAs(es.filename)
+		return products, es.saveFile()
+	}
+	if err != nil {
+		// Поврежденный или недоступный файл нельзя заменять пустым
+		es.file = nil
+		return products, fmt.Errorf("ошибка при открытии файла %s: %w", es.filename, err)
+	}
+
+	sheet, err := es.productsSheet()
+	if err != nil {
+		return products, err
+	}
+	if _, err := migrateWorkbook(es.file, sheet, es.aliases); err != nil {
+		return products, err
 	}
 
 	// Читаем данные
-	rows, err := es.file.GetRows("Sheet1")
+	rows, err := es.file.GetRows(sheet)
 	if err != nil {
 		return products, fmt.Errorf("ошибка при чтении строк: %w", err)
 	}
+	es.layout, err = readLayout(sheet, rows, es.aliases)
+	if err != nil {
+		return products, err
+	}
+	products, es.rows, es.report = parseProducts(es.layout, rows)
 
-	// Пропускаем заголовок
-	for i := 1; i < len(rows); i++ {
-		row := rows[i]
-		if len(row) < 4 {
+	es.report.Quarantine, err = readQuarantine(es.file, es.report.Quarantine)
+	if err != nil {
+		return products, err
+	}
+
+	es.constants, err = readConstants(es.file)
+	if err != nil {
+		return products, err
+	}
+
+	return products, nil
+}
+
+// Save сохраняет данные в Excel файл. Книга, прочитанная при загрузке, изменяется
+// на месте: перезаписываются только ячейки с данными, поэтому оформление,
+// закрепленные строки и другие листы сохраняются. Без загрузки создается новый файл.
+func (es *ExcelStorage) Save(products models.Products) error {
+	if es.file == nil {
+		if err := es.newWorkbook(); err != nil {
+			return err
+		}
+	}
+
+	rows := es.rows
+	if err := es.write(products); err != nil {
+		// Приложение отменяет несохраненное изменение, поэтому строки продуктов
+		// считаются расположенными как после последнего успешного сохранения
+		es.rows = rows
+		es.discard()
+		return err
+	}
+	return nil
+}
+
+// discard отбрасывает несохраненные изменения книги, открывая файл с диска
+// заново, чтобы следующее сохранение констант не записало их в файл.
+// Если файл открыть не удалось, книга читается заново при следующем сохранении.
+func (es *ExcelStorage) discard() {
+	if es.file != nil {
+		es.file.Close()
+		es.file = nil
+	}
+	file, err := excelize.OpenFile(es.filename)
+	if err != nil {
+		return
+	}
+	// Миграции схемы, примененные при загрузке, еще не записаны в файл
+	if _, err := migrateWorkbook(file, es.layout.sheet, es.aliases); err != nil {
+		file.Close()
+		return
+	}
+	es.file = file
+}
+
+// write записывает продукты, константы и карантин в книгу и сохраняет файл
+func (es *ExcelStorage) write(products models.Products) error {
+	if err := es.writeProducts(products); err != nil {
+		return err
+	}
+	if err := writeConstants(es.file, es.constants); err != nil {
+		return err
+	}
+	if err := writeQuarantine(es.file, es.report.Quarantine); err != nil {
+		return err
+	}
+	return es.saveFile()
+}
+
+// newWorkbook создает книгу текущей версии схемы с листом продуктов,
+// у которого закреплена строка заголовков
+func (es *ExcelStorage) newWorkbook() error {
+	es.file = excelize.NewFile()
+	es.layout = defaultLayout()
+	es.layout.sheet = es.newSheetName()
+	es.rows = nil
+
+	sheet := es.layout.sheet
+	if sheet != defaultProductsSheet {
+		if err := es.file.SetSheetName(defaultProductsSheet, sheet); err != nil {
+			return fmt.Errorf("ошибка при создании листа %s: %w", sheet, err)
+		}
+	}
+	panes := &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}
+	if err := es.file.SetPanes(sheet, panes); err != nil {
+		return fmt.Errorf("ошибка при закреплении заголовков: %w", err)
+	}
+	return writeSchemaVersion(es.file)
+}
+
+// newSheetName возвращает имя листа с продуктами для нового файла
+func (es *ExcelStorage) newSheetName() string {
+	if es.sheet != "" {
+		return es.sheet
+	}
+	return defaultProductsSheet
+}
+
+// productsSheet возвращает имя листа с продуктами в открытом файле
+func (es *ExcelStorage) productsSheet() (string, error) {
+	if es.sheet != "" {
+		if index, err := es.file.GetSheetIndex(es.sheet); err != nil || index < 0 {
+			return "", fmt.Errorf("в файле %s нет листа %q", es.filename, es.sheet)
+		}
+		return es.sheet, nil
+	}
+
+	sheets := es.file.GetSheetList()
+	if slices.Contains(sheets, defaultProductsSheet) {
+		return defaultProductsSheet, nil
+	}
+	for _, sheet := range sheets {
+		if sheet != constantsSheet && sheet != quarantineSheet && sheet != metaSheet {
+			return sheet, nil
+		}
+	}
+	return "", fmt.Errorf("в файле %s нет листа с продуктами", es.filename)
+}
+
+// LoadReport возвращает проблемы, обнаруженные при последней загрузке, и строки карантина
+func (es *ExcelStorage) LoadReport() LoadReport {
+	return es.report.clone()
+}
+
+// SetQuarantine заменяет строки карантина, они записываются при следующем Save
+func (es *ExcelStorage) SetQuarantine(rows []QuarantinedRow) {
+	es.report.Quarantine = LoadReport{Quarantine: rows}.clone().Quarantine
+}
+
+// LoadConstants возвращает константы, прочитанные из файла при последнем вызове Load
+func (es *ExcelStorage) LoadConstants() (models.Constants, error) {
+	return es.constants, nil
+}
+
+// SaveConstants сохраняет константы на отдельный лист, не изменяя лист с продуктами
+func (es *ExcelStorage) SaveConstants(constants models.Constants) error {
+	if es.file == nil {
+		if _, err := es.Load(); err != nil {
+			return err
+		}
+	}
+
+	previous := es.constants
+	es.constants = constants
+	err := writeConstants(es.file, constants)
+	if err == nil {
+		err = es.saveFile()
+	}
+	if err != nil {
+		es.constants = previous
+		es.discard()
+	}
+	return err
+}
+
+// writeWorkbook записывает книгу в поток. Вынесена в переменную,
+// чтобы тесты могли имитировать сбой во время записи.
+var writeWorkbook = func(file *excelize.File, w io.Writer) error {
+	_, err := file.WriteTo(w)
+	return err
+}
+
+// Changed сообщает, изменила ли другая программа файл после последней загрузки или сохранения
+func (es *ExcelStorage) Changed() (bool, error) {
+	return es.watcher.changed(es.filename)
+}
+
+// saveFile атомарно сохраняет текущую книгу: сбой во время записи
+// не повреждает ранее сохраненный файл. Если файл изменен другой программой,
+// он не перезаписывается и возвращается ErrConflict. Если включено
+// резервное копирование, перед записью сохраняется копия предыдущей версии файла.
+func (es *ExcelStorage) saveFile() error {
+	if err := es.lock.writable(); err != nil {
+		return err
+	}
+
+	changed, err := es.Changed()
+	if err != nil {
+		return err
+	}
+	if changed {
+		return fmt.Errorf("%w: %s", ErrConflict, es.filename)
+	}
+
+	if es.backupPolicy != nil {
+		if _, err := es.backups().Create(es.filename); err != nil {
+			return err
+		}
+	}
+
+	err = writeFileAtomic(es.filename, func(w io.Writer) error {
+		return writeWorkbook(es.file, w)
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении файла: %w", err)
+	}
+	return es.watcher.remember(es.filename)
+}
+
+// ListBackups возвращает резервные копии текущего файла, начиная с самой новой
+func (es *ExcelStorage) ListBackups() ([]Backup, error) {
+	return es.backups().List(es.filename)
+}
+
+// RestoreBackup заменяет файл базы данных резервной копией.
+// Текущая версия файла предварительно сохраняется как новая резервная копия,
+// поэтому восстановление можно отменить. После восстановления нужно вызвать Load.
+func (es *ExcelStorage) RestoreBackup(id string) error {
+	if err := es.lock.writable(); err != nil {
+		return err
+	}
+
+	backups := es.backups()
+	path, err := backups.Path(es.filename, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := backups.Create(es.filename); err != nil {
+		return err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии резервной копии: %w", err)
+	}
+	defer src.Close()
+
+	if es.file != nil {
+		if err := es.file.Close(); err != nil {
+			return fmt.Errorf("ошибка при закрытии файла: %w", err)
+		}
+		es.file = nil
+	}
+
+	err = writeFileAtomic(es.filename, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при восстановлении резервной копии: %w", err)
+	}
+	return nil
+}
+
+// writeProducts записывает продукты на лист и в колонки, прочитанные при загрузке.
+// Ячейки перезаписываются по одной, поэтому их оформление сохраняется.
+// Дополнительные колонки записываются, только если продукт переместился
+// в другую строку: иначе их значения и формулы остаются нетронутыми.
+func (es *ExcelStorage) writeProducts(products models.Products) error {
+	sheet := es.layout.sheet
+	old, err := es.file.GetRows(sheet)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении строк: %w", err)
+	}
+
+	// Заголовки записываются только у колонок, которых не было в файле
+	for j, column := range es.layout.columns {
+		if j < len(firstRow(old)) && old[0][j] == column.header {
 			continue
 		}
+		if err := setCell(es.file, sheet, j, 1, column.header); err != nil {
+			return fmt.Errorf("ошибка при записи заголовков: %w", err)
+		}
+	}
 
-		id, err := strconv.Atoi(row[0])
-		if err != nil {
-			// Пропускаем строку с некорректным ID
+	rows := make(map[int]sheetRow, len(products))
+	for i, product := range products {
+		number := i + 2
+		previous := es.rows[product.ID]
+		extra := 0
+		for j, column := range es.layout.columns {
+			var value interface{}
+			switch column.field {
+			case ColumnID:
+				value = product.ID
+			case ColumnName:
+				value = product.Name
+			case ColumnProcessingTime:
+				value = product.ProcessingTime
+			case ColumnTimeCalculation:
+				value = product.TimeCalculation
+			case ColumnVersion:
+				value = product.Version
+			case ColumnUpdatedAt:
+				value = formatUpdatedAt(product.UpdatedAt)
+			default:
+				// У новых продуктов дополнительные колонки пустые
+				index := extra
+				extra++
+				if previous.number == number {
+					continue
+				}
+				if index < len(previous.extras) && previous.extras[index] != "" {
+					value = previous.extras[index]
+				}
+			}
+			if err := setCell(es.file, sheet, j, number, value); err != nil {
+				return fmt.Errorf("ошибка при записи продукта #%d: %w", product.ID, err)
+			}
+		}
+		rows[product.ID] = sheetRow{number: number, extras: previous.extras}
+	}
+	es.rows = rows
+
+	return clearRows(es.file, sheet, len(products)+2, old)
+}
+
+// setCell записывает значение в ячейку колонки column (с 0) и строки row (с 1)
+func setCell(file *excelize.File, sheet string, column, row int, value interface{}) error {
+	cell, err := excelize.CoordinatesToCellName(column+1, row)
+	if err != nil {
+		return err
+	}
+	if err := file.SetCellValue(sheet, cell, value); err != nil {
+		return fmt.Errorf("ошибка при записи ячейки %s!%s: %w", sheet, cell, err)
+	}
+	return nil
+}
+
+// firstRow возвращает первую строку листа или nil, если лист пуст
+func firstRow(rows [][]string) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+	return rows[0]
+}
+
+// clearRows очищает значения ячеек листа, начиная со строки from (с 1),
+// которые были заполнены в прежнем содержимом листа old. Оформление ячеек остается.
+func clearRows(file *excelize.File, sheet string, from int, old [][]string) error {
+	for row := from; row <= len(old); row++ {
+		for column, value := range old[row-1] {
+			if value == "" {
+				continue
+			}
+			cell, err := excelize.CoordinatesToCellName(column+1, row)
+			if err != nil {
+				return err
+			}
+			if err := file.SetCellValue(sheet, cell, nil); err != nil {
+				return fmt.Errorf("ошибка при очистке ячейки %s!%s: %w", sheet, cell, err)
+			}
+		}
+	}
+	return nil
+}
+
+// clearColumns очищает ячейки строк old, начиная с колонки from (с 0)
+func clearColumns(file *excelize.File, sheet string, from int, old [][]string) error {
+	for row, values := range old {
+		for column := from; column < len(values); column++ {
+			if values[column] == "" {
+				continue
+			}
+			cell, err := excelize.CoordinatesToCellName(column+1, row+1)
+			if err != nil {
+				return err
+			}
+			if err := file.SetCellValue(sheet, cell, nil); err != nil {
+				return fmt.Errorf("ошибка при очистке ячейки %s!%s: %w", sheet, cell, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readLayout определяет расположение колонок по первой строке листа.
+// У пустого листа колонки располагаются как в новом файле.
+func readLayout(sheet string, rows [][]string, aliases ColumnAliases) (columnLayout, error) {
+	if len(rows) == 0 {
+		layout := defaultLayout()
+		layout.sheet = sheet
+		return layout, nil
+	}
+
+	width := 0
+	for _, row := range rows[1:] {
+		width = max(width, len(row))
+	}
+	layout, err := parseLayout(sheet, rows[0], width, aliases)
+	layout.sheet = sheet
+	return layout, err
+}
+
+// parseProducts разбирает строки листа с продуктами, пропуская заголовок,
+// и возвращает строки продуктов со значениями дополнительных колонок по ID.
+// Проблемы в отдельных ячейках попадают в отчет, а строки без корректного
+// уникального ID помещаются в карантин вместо того, чтобы пропасть.
+func parseProducts(layout columnLayout, rows [][]string) (models.Products, map[int]sheetRow, LoadReport) {
+	var products models.Products
+	var report LoadReport
+	placed := make(map[int]sheetRow)
+	seen := make(map[int]int) // ID -> номер строки, в которой он встретился
+	sheet := layout.sheet
+	extraHeaders := layout.extraHeaders()
+
+	for i := 1; i < len(rows); i++ {
+		rowNumber := i + 1
+		// Пустые ячейки в конце строки excelize не возвращает
+		row := make([]string, len(layout.columns))
+		copy(row, rows[i])
+		if slices.IndexFunc(row, func(cell string) bool { return strings.TrimSpace(cell) != "" }) < 0 {
 			continue
 		}
 
-		processingTime, err := strconv.ParseFloat(row[2], 64)
+		// Значения колонок продукта в порядке productFields и дополнительных колонок
+		cells := make([]string, productColumnCount)
+		for j, field := range productFields {
+			cells[j] = row[layout.index(field)]
+		}
+		var extra []string
+		for j, column := range layout.columns {
+			if column.field == "" {
+				extra = append(extra, row[j])
+			}
+		}
+
+		issue := func(field int, reason string) LoadIssue {
+			name, _ := excelize.ColumnNumberToName(layout.index(productFields[field]) + 1)
+			return LoadIssue{Sheet: sheet, Row: rowNumber, Column: name, Value: cells[field], Reason: reason}
+		}
+		quarantine := func(issue LoadIssue) {
+			report.Issues = append(report.Issues, issue)
+			// В карантин попадает вся строка вместе с дополнительными колонками
+			report.Quarantine = append(report.Quarantine, QuarantinedRow{
+				Key:          quarantineKey(sheet, rowNumber),
+				Sheet:        sheet,
+				Row:          rowNumber,
+				Cells:        slices.Concat(cells, extra),
+				ExtraHeaders: extraHeaders,
+				Reason:       issue.Reason,
+			})
+		}
+
+		id, err := strconv.Atoi(strings.TrimSpace(cells[0]))
 		if err != nil {
-			// Если не удалось преобразовать, устанавливаем в 0
-			processingTime = 0
+			quarantine(issue(0, "ID не является целым числом"))
+			continue
+		}
+		if id <= 0 {
+			quarantine(issue(0, "ID должен быть больше нуля"))
+			continue
 		}
+		if first, ok := seen[id]; ok {
+			quarantine(issue(0, fmt.Sprintf("ID %d уже используется в строке %d", id, first)))
+			continue
+		}
+		seen[id] = rowNumber
 
 		product := models.Product{
 			ID:              id,
-			Name:            row[1],
-			ProcessingTime:  processingTime,
-			TimeCalculation: row[3],
+			Name:            cells[1],
+			TimeCalculation: cells[3],
+		}
+		if strings.TrimSpace(product.Name) == "" {
+			report.Issues = append(report.Issues, issue(1, "пустое наименование"))
+		}
+
+		// Время обработки могли ввести вручную с десятичной запятой,
+		// а пустое время или колонка, которой нет в файле, считаются нулем
+		timeValue := strings.ReplaceAll(strings.TrimSpace(cells[2]), ",", ".")
+		if timeValue != "" {
+			if product.ProcessingTime, err = strconv.ParseFloat(timeValue, 64); err != nil {
+				report.Issues = append(report.Issues, issue(2, "время обработки не является числом, принято 0"))
+			}
+		}
+
+		// Файлы, созданные до появления версий, не содержат последних колонок
+		if cells[4] != "" {
+			if product.Version, err = strconv.Atoi(strings.TrimSpace(cells[4])); err != nil {
+				report.Issues = append(report.Issues, issue(4, "версия не является целым числом, принята 0"))
+			}
+		}
+		if cells[5] != "" {
+			if product.UpdatedAt = parseUpdatedAt(cells[5]); product.UpdatedAt.IsZero() {
+				report.Issues = append(report.Issues, issue(5, "некорректное время изменения"))
+			}
 		}
 		products = append(products, product)
+		placed[id] = sheetRow{number: rowNumber, extras: extra}
 	}
 
-	return products, nil
+	return products, placed, report
 }
 
-// Save сохраняет данные в Excel файл
-func (es *ExcelStorage) Save(products models.Products) error {
-	// Закрываем текущий файл если он открыт
-	if es.file != nil {
-		if err := es.file.Close(); err != nil {
-			return fmt.Errorf("ошибка при закрытии файла: %w", err)
+// readQuarantine читает строки карантина, сохраненные ранее, и добавляет
+// к ним строки, помещенные в карантин при текущей загрузке
+func readQuarantine(file *excelize.File, loaded []QuarantinedRow) ([]QuarantinedRow, error) {
+	index, err := file.GetSheetIndex(quarantineSheet)
+	if err != nil || index < 0 {
+		return loaded, nil
+	}
+
+	rows, err := file.GetRows(quarantineSheet)
+	if err != nil {
+		return loaded, fmt.Errorf("ошибка при чтении карантина: %w", err)
+	}
+
+	// За колонками продукта и причиной следуют дополнительные колонки
+	var quarantine []QuarantinedRow
+	var extraHeaders []string
+	if header := firstRow(rows); len(header) > productColumnCount+1 {
+		extraHeaders = header[productColumnCount+1:]
+	}
+	// Пропускаем заголовок
+	for i := 1; i < len(rows); i++ {
+		cells := make([]string, max(len(rows[i]), productColumnCount+1+len(extraHeaders)))
+		copy(cells, rows[i])
+		headers := make([]string, len(cells)-productColumnCount-1)
+		copy(headers, extraHeaders)
+		quarantine = append(quarantine, QuarantinedRow{
+			Key:          quarantineKey(quarantineSheet, i+1),
+			Sheet:        quarantineSheet,
+			Row:          i + 1,
+			Cells:        slices.Concat(cells[:productColumnCount], cells[productColumnCount+1:]),
+			ExtraHeaders: headers,
+			Reason:       cells[productColumnCount],
+		})
+	}
+	return append(quarantine, loaded...), nil
+}
+
+// writeQuarantine перезаписывает лист карантина: исходные значения колонок
+// продукта, причину и дополнительные колонки. Если карантин пуст, лист удаляется.
+func writeQuarantine(file *excelize.File, quarantine []QuarantinedRow) error {
+	old, err := prepareSheet(file, quarantineSheet, len(quarantine) > 0)
+	if err != nil || len(quarantine) == 0 {
+		return err
+	}
+
+	extraHeaders := quarantineExtraHeaders(quarantine)
+	header := []interface{}{"ID", "Наименование", "Время обработки в часах", "Расчет времени", "Версия", "Изменено", "Причина"}
+	for _, extraHeader := range extraHeaders {
+		header = append(header, extraHeader)
+	}
+	if err := file.SetSheetRow(quarantineSheet, "A1", &header); err != nil {
+		return fmt.Errorf("ошибка при записи заголовка карантина: %w", err)
+	}
+
+	for i, row := range quarantine {
+		values := make([]interface{}, len(header))
+		for j := range values {
+			values[j] = ""
+		}
+		for j := 0; j < min(len(row.Cells), productColumnCount); j++ {
+			values[j] = row.Cells[j]
+		}
+		values[productColumnCount] = row.Reason
+		for j := productColumnCount; j < len(row.Cells); j++ {
+			column := extraColumn(extraHeaders, row.ExtraHeaders, j-productColumnCount)
+			values[productColumnCount+1+column] = row.Cells[j]
 		}
+		if err := file.SetSheetRow(quarantineSheet, fmt.Sprintf("A%d", i+2), &values); err != nil {
+			return fmt.Errorf("ошибка при записи строки карантина: %w", err)
+		}
+	}
+	// Дополнительные колонки удаленных строк больше не нужны
+	if err := clearColumns(file, quarantineSheet, len(header), old[:min(len(old), len(quarantine)+1)]); err != nil {
+		return err
 	}
+	return clearRows(file, quarantineSheet, len(quarantine)+2, old)
+}
 
-	// Создаем новый файл
-	es.file = excelize.NewFile()
+// quarantineExtraHeaders объединяет заголовки дополнительных колонок строк
+// карантина: одноименные колонки разных строк записываются в одну колонку листа
+func quarantineExtraHeaders(quarantine []QuarantinedRow) []string {
+	var headers []string
+	for _, row := range quarantine {
+		for i := productColumnCount; i < len(row.Cells); i++ {
+			if extraColumn(headers, row.ExtraHeaders, i-productColumnCount) < 0 {
+				headers = append(headers, extraHeader(row.ExtraHeaders, i-productColumnCount))
+			}
+		}
+	}
+	return headers
+}
+
+// extraColumn возвращает номер колонки в headers для дополнительной колонки i
+// строки с заголовками rowHeaders или -1, если такой колонки нет. Несколько
+// одноименных колонок строки попадают в одноименные колонки листа по порядку.
+func extraColumn(headers, rowHeaders []string, i int) int {
+	header := extraHeader(rowHeaders, i)
+	n := 0
+	for j := 0; j < i; j++ {
+		if extraHeader(rowHeaders, j) == header {
+			n++
+		}
+	}
+	for j, existing := range headers {
+		if existing != header {
+			continue
+		}
+		if n == 0 {
+			return j
+		}
+		n--
+	}
+	return -1
+}
+
+// extraHeader возвращает заголовок дополнительной колонки i; у колонок
+// без заголовка он пустой
+func extraHeader(headers []string, i int) string {
+	if i < len(headers) {
+		return headers[i]
+	}
+	return ""
+}
+
+// readConstants читает константы с листа констант, если он есть
+func readConstants(file *excelize.File) (models.Constants, error) {
+	var constants models.Constants
+
+	index, err := file.GetSheetIndex(constantsSheet)
+	if err != nil || index < 0 {
+		return constants, nil
+	}
+
+	rows, err := file.GetRows(constantsSheet)
+	if err != nil {
+		return constants, fmt.Errorf("ошибка при чтении констант: %w", err)
+	}
+
+	// Пропускаем заголовок
+	for i := 1; i < len(rows); i++ {
+		row := rows[i]
+		if len(row) < 2 || row[0] == "" {
+			continue
+		}
 
-	// Записываем заголовки
-	if err := es.file.SetCellValue("Sheet1", "A1", "ID"); err != nil {
-		return fmt.Errorf("ошибка при установке заголовка ID: %w", err)
+		value, err := strconv.ParseFloat(row[1], 64)
+		if err != nil {
+			// Пропускаем константу с некорректным значением
+			continue
+		}
+		constants = append(constants, models.Constant{Name: row[0], Value: value})
 	}
-	if err := es.file.SetCellValue("Sheet1", "B1", "Наименование"); err != nil {
-		return fmt.Errorf("ошибка при установке заголовка Наименование: %w", err)
+
+	return constants, nil
+}
+
+// writeConstants перезаписывает лист констант, сохраняя его оформление.
+// Если констант нет, лист удаляется.
+func writeConstants(file *excelize.File, constants models.Constants) error {
+	old, err := prepareSheet(file, constantsSheet, len(constants) > 0)
+	if err != nil || len(constants) == 0 {
+		return err
 	}
-	if err := es.file.SetCellValue("Sheet1", "C1", "Время обработки в часах"); err != nil {
-		return fmt.Errorf("ошибка при установке заголовка Время обработки: %w", err)
+
+	if err := file.SetCellValue(constantsSheet, "A1", "Имя"); err != nil {
+		return fmt.Errorf("ошибка при установке заголовка Имя: %w", err)
 	}
-	if err := es.file.SetCellValue("Sheet1", "D1", "Расчет времени"); err != nil {
-		return fmt.Errorf("ошибка при установке заголовка Расчет времени: %w", err)
+	if err := file.SetCellValue(constantsSheet, "B1", "Значение"); err != nil {
+		return fmt.Errorf("ошибка при установке заголовка Значение: %w", err)
 	}
 
-	// Записываем данные
-	for i, product := range products {
+	for i, constant := range constants {
 		row := i + 2
-		if err := es.file.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), product.ID); err != nil {
-			return fmt.Errorf("ошибка при записи ID: %w", err)
+		if err := file.SetCellValue(constantsSheet, fmt.Sprintf("A%d", row), constant.Name); err != nil {
+			return fmt.Errorf("ошибка при записи имени константы: %w", err)
 		}
-		if err := es.file.SetCellValue("Sheet1", fmt.Sprintf("B%d", row), product.Name); err != nil {
-			return fmt.Errorf("ошибка при записи названия: %w", err)
+		if err := file.SetCellValue(constantsSheet, fmt.Sprintf("B%d", row), constant.Value); err != nil {
+			return fmt.Errorf("ошибка при записи значения константы: %w", err)
 		}
-		if err := es.file.SetCellValue("Sheet1", fmt.Sprintf("C%d", row), product.ProcessingTime); err != nil {
-			return fmt.Errorf("ошибка при записи времени обработки: %w", err)
+	}
+	return clearRows(file, constantsSheet, len(constants)+2, old)
+}
+
+// prepareSheet готовит служебный лист к перезаписи и возвращает его прежнее
+// содержимое. Существующий лист сохраняется вместе с оформлением, недостающий
+// создается, а если лист не нужен (keep равен false), он удаляется.
+func prepareSheet(file *excelize.File, sheet string, keep bool) ([][]string, error) {
+	index, err := file.GetSheetIndex(sheet)
+	exists := err == nil && index >= 0
+	switch {
+	case !keep && exists:
+		if err := file.DeleteSheet(sheet); err != nil {
+			return nil, fmt.Errorf("ошибка при удалении листа %s: %w", sheet, err)
 		}
-		if err := es.file.SetCellValue("Sheet1", fmt.Sprintf("D%d", row), product.TimeCalculation); err != nil {
-			return fmt.Errorf("ошибка при записи расчета времени: %w", err)
+		return nil, nil
+	case !keep:
+		return nil, nil
+	case !exists:
+		if _, err := file.NewSheet(sheet); err != nil {
+			return nil, fmt.Errorf("ошибка при создании листа %s: %w", sheet, err)
 		}
+		return nil, nil
 	}
 
-	// Сохраняем файл
-	if err := es.file.SaveAs(es.filename); err != nil {
-		return fmt.Errorf("ошибка при сохранении файла: %w", err)
+	rows, err := file.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении листа %s: %w", sheet, err)
 	}
-	return nil
+	return rows, nil
 }
 
-// Close закрывает файл Excel
+// Close закрывает файл Excel и снимает блокировку
 func (es *ExcelStorage) Close() error {
+	var err error
 	if es.file != nil {
-		return es.file.Close()
+		err = es.file.Close()
 	}
-	return nil
+	return errors.Join(err, es.lock.release())
 }
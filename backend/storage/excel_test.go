@@ -1,11 +1,17 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+	"github.com/xuri/excelize/v2"
 )
 
 func TestExcelStorage_WithFilename(t *testing.T) {
@@ -84,6 +90,31 @@ func TestExcelStorage_LoadNonExistentFile(t *testing.T) {
 	}
 }
 
+func TestExcelStorage_LoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	// Поврежденный файл не заменяется новым
+	damaged := filepath.Join(dir, "damaged.xlsx")
+	if err := os.WriteFile(damaged, []byte("не Excel"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := NewExcelStorage().WithFilename(damaged).Load(); err == nil {
+		t.Errorf("Load() поврежденного файла должен вернуть ошибку")
+	}
+	if data, err := os.ReadFile(damaged); err != nil || string(data) != "не Excel" {
+		t.Errorf("Поврежденный файл после Load() = %q, %v, want прежнее содержимое", data, err)
+	}
+
+	// В режиме просмотра отсутствующий файл не создается
+	missing := filepath.Join(dir, "missing.xlsx")
+	if _, err := NewExcelStorage().WithFilename(missing).WithReadOnly().Load(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() в режиме просмотра error = %v, want %v", err, os.ErrNotExist)
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() в режиме просмотра создал файл, Stat() error = %v", err)
+	}
+}
+
 func TestExcelStorage_SaveError(t *testing.T) {
 	// Тестируем ошибку при сохранении в некорректную директорию
 	invalidPath := "/invalid/directory/file.xlsx"
@@ -94,3 +125,738 @@ func TestExcelStorage_SaveError(t *testing.T) {
 		t.Errorf("Save() должен вернуть ошибку при некорректном пути")
 	}
 }
+
+func TestExcelStorage_SaveAndLoadConstants(t *testing.T) {
+	tempFile := "test_constants.xlsx"
+	defer os.Remove(tempFile)
+
+	storage := NewExcelStorage().WithFilename(tempFile)
+	if _, err := storage.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	testConstants := models.Constants{
+		{Name: "setup_cnc", Value: 0.75},
+		{Name: "changeover", Value: 0.5},
+	}
+	testProducts := models.Products{
+		{ID: 1, Name: "Тестовый продукт", ProcessingTime: 7.05, TimeCalculation: "setup_cnc + 3*2.1"},
+	}
+
+	if err := storage.SaveConstants(testConstants); err != nil {
+		t.Fatalf("SaveConstants() error = %v", err)
+	}
+	// Сохранение продуктов не должно терять константы
+	if err := storage.Save(testProducts); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reloaded := NewExcelStorage().WithFilename(tempFile)
+	defer reloaded.Close()
+	loadedProducts, err := reloaded.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	loadedConstants, err := reloaded.LoadConstants()
+	if err != nil {
+		t.Fatalf("LoadConstants() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(loadedConstants, testConstants) {
+		t.Errorf("LoadConstants() = %v, want %v", loadedConstants, testConstants)
+	}
+	if !reflect.DeepEqual(loadedProducts, testProducts) {
+		t.Errorf("Load() = %v, want %v", loadedProducts, testProducts)
+	}
+
+	// Сохранение констант не должно затрагивать продукты
+	if err := reloaded.SaveConstants(models.Constants{}); err != nil {
+		t.Fatalf("SaveConstants() error = %v", err)
+	}
+	loadedProducts, err = reloaded.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loadedProducts, testProducts) {
+		t.Errorf("Load() после SaveConstants() = %v, want %v", loadedProducts, testProducts)
+	}
+	if loadedConstants, _ = reloaded.LoadConstants(); len(loadedConstants) != 0 {
+		t.Errorf("LoadConstants() = %v, want пустой список", loadedConstants)
+	}
+}
+
+func TestExcelStorage_SaveFailureKeepsPreviousData(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "database.xlsx")
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+
+	original := models.Products{
+		{ID: 1, Name: "Тестовый продукт 1", ProcessingTime: 1.5, TimeCalculation: "1.5"},
+	}
+	if err := storage.Save(original); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// Имитируем сбой посреди записи: часть данных записана, затем ошибка
+	defer func(orig func(*excelize.File, io.Writer) error) { writeWorkbook = orig }(writeWorkbook)
+	writeWorkbook = func(_ *excelize.File, w io.Writer) error {
+		if _, err := w.Write([]byte("PK\x03\x04 частично записанный файл")); err != nil {
+			return err
+		}
+		return errors.New("сбой записи")
+	}
+
+	err := storage.Save(models.Products{{ID: 2, Name: "Новый продукт", ProcessingTime: 2, TimeCalculation: "2"}})
+	if err == nil {
+		t.Fatalf("Save() должен вернуть ошибку при сбое записи")
+	}
+
+	// Исходный файл не поврежден и содержит прежние данные
+	reloaded := NewExcelStorage().WithFilename(filename)
+	defer reloaded.Close()
+	loaded, err := reloaded.Load()
+	if err != nil {
+		t.Fatalf("Load() после сбоя записи error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, original) {
+		t.Errorf("Load() после сбоя записи = %v, want %v", loaded, original)
+	}
+
+	// Временные файлы не остаются в директории
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("В директории остались лишние файлы: %v", names)
+	}
+}
+
+// writeExternal записывает продукты в файл так, как это сделала бы другая программа
+func writeExternal(t *testing.T, filename string, products models.Products) {
+	t.Helper()
+	other := NewExcelStorage().WithFilename(filename)
+	defer other.Close()
+	if err := other.Save(products); err != nil {
+		t.Fatalf("Save() другой программой error = %v", err)
+	}
+}
+
+func TestExcelStorage_ExternalChange(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+
+	if err := storage.Save(models.Products{{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1"}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := storage.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if changed, err := storage.Changed(); err != nil || changed {
+		t.Fatalf("Changed() = %v, %v, want false", changed, err)
+	}
+
+	// Собственное сохранение изменением файла не считается
+	ours := models.Products{{ID: 1, Name: "Продукт 1", ProcessingTime: 2, TimeCalculation: "2"}}
+	if err := storage.Save(ours); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if changed, err := storage.Changed(); err != nil || changed {
+		t.Fatalf("После Save() Changed() = %v, %v, want false", changed, err)
+	}
+
+	external := models.Products{{ID: 7, Name: "Внешний продукт", ProcessingTime: 3, TimeCalculation: "3"}}
+	writeExternal(t, filename, external)
+	if changed, err := storage.Changed(); err != nil || !changed {
+		t.Fatalf("После внешней записи Changed() = %v, %v, want true", changed, err)
+	}
+
+	// Сохранение не перезаписывает чужие изменения
+	if err := storage.Save(ours); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Save() error = %v, want %v", err, ErrConflict)
+	}
+	loaded, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, external) {
+		t.Errorf("Load() = %v, want %v", loaded, external)
+	}
+
+	// После загрузки изменения приняты и сохранение снова возможно
+	if err := storage.Save(ours); err != nil {
+		t.Errorf("Save() после Load() error = %v", err)
+	}
+}
+
+func TestExcelStorage_TouchIsNotChange(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+
+	if _, err := storage.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filename, later, later); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+	if changed, err := storage.Changed(); err != nil || changed {
+		t.Errorf("После изменения времени файла Changed() = %v, %v, want false", changed, err)
+	}
+
+	if err := os.Remove(filename); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if changed, err := storage.Changed(); err != nil || !changed {
+		t.Errorf("После удаления файла Changed() = %v, %v, want true", changed, err)
+	}
+}
+
+func TestExcelStorage_Versions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+
+	testProducts := models.Products{
+		{ID: 1, Name: "Без версии", ProcessingTime: 1, TimeCalculation: "1"},
+		{
+			ID: 2, Name: "С версией", ProcessingTime: 2, TimeCalculation: "2",
+			Version: 3, UpdatedAt: time.Date(2025, 3, 1, 10, 30, 15, 0, time.UTC),
+		},
+	}
+	if err := storage.Save(testProducts); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, testProducts) {
+		t.Errorf("Load() = %v, want %v", loaded, testProducts)
+	}
+}
+
+// writeRows создает Excel файл с указанными строками листа с продуктами
+func writeRows(t *testing.T, filename string, rows [][]interface{}) {
+	t.Helper()
+	writeSheetRows(t, filename, defaultProductsSheet, rows)
+}
+
+// writeSheetRows создает Excel файл текущей версии схемы с листом продуктов sheet
+func writeSheetRows(t *testing.T, filename, sheet string, rows [][]interface{}) {
+	t.Helper()
+	file := excelize.NewFile()
+	defer file.Close()
+	if sheet != defaultProductsSheet {
+		if err := file.SetSheetName(defaultProductsSheet, sheet); err != nil {
+			t.Fatalf("SetSheetName() error = %v", err)
+		}
+	}
+	for i, row := range rows {
+		if err := file.SetSheetRow(sheet, fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("SetSheetRow() error = %v", err)
+		}
+	}
+	// Файл текущей версии схемы загружается без миграций
+	if err := writeSchemaVersion(file); err != nil {
+		t.Fatalf("writeSchemaVersion() error = %v", err)
+	}
+	if err := file.SaveAs(filename); err != nil {
+		t.Fatalf("SaveAs() error = %v", err)
+	}
+}
+
+func TestExcelStorage_LoadReport(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writeRows(t, filename, [][]interface{}{
+		{"ID", "Наименование", "Время обработки в часах", "Расчет времени", "Версия", "Изменено"},
+		{1, "Вал", 1.5, "1.5", 2, "2025-03-01T10:30:00Z"},
+		{"x7", "Корпус", 2, "2"},
+		{2, "Без формулы", 3},
+		{},
+		{1, "Дубликат", 1, "1"},
+		{3, "", "полтора", "1,5", "вторая", "вчера"},
+		{-4, "Отрицательный", 1, "1"},
+		{5, "С запятой", "2,5", "2,5"},
+	})
+
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+	products, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Строки с корректным ID загружаются, даже если другие ячейки некорректны
+	wantProducts := models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 1.5, TimeCalculation: "1.5", Version: 2, UpdatedAt: time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{ID: 2, Name: "Без формулы", ProcessingTime: 3},
+		{ID: 3, TimeCalculation: "1,5"},
+		{ID: 5, Name: "С запятой", ProcessingTime: 2.5, TimeCalculation: "2,5"},
+	}
+	if !reflect.DeepEqual(products, wantProducts) {
+		t.Errorf("Load() = %v, want %v", products, wantProducts)
+	}
+
+	report := storage.LoadReport()
+	wantIssues := []LoadIssue{
+		{Sheet: defaultProductsSheet, Row: 3, Column: "A", Value: "x7", Reason: "ID не является целым числом"},
+		{Sheet: defaultProductsSheet, Row: 6, Column: "A", Value: "1", Reason: "ID 1 уже используется в строке 2"},
+		{Sheet: defaultProductsSheet, Row: 7, Column: "B", Value: "", Reason: "пустое наименование"},
+		{Sheet: defaultProductsSheet, Row: 7, Column: "C", Value: "полтора", Reason: "время обработки не является числом, принято 0"},
+		{Sheet: defaultProductsSheet, Row: 7, Column: "E", Value: "вторая", Reason: "версия не является целым числом, принята 0"},
+		{Sheet: defaultProductsSheet, Row: 7, Column: "F", Value: "вчера", Reason: "некорректное время изменения"},
+		{Sheet: defaultProductsSheet, Row: 8, Column: "A", Value: "-4", Reason: "ID должен быть больше нуля"},
+	}
+	if !reflect.DeepEqual(report.Issues, wantIssues) {
+		t.Errorf("LoadReport().Issues = %v, want %v", report.Issues, wantIssues)
+	}
+	var keys []string
+	for _, row := range report.Quarantine {
+		keys = append(keys, row.Key)
+	}
+	if want := []string{"Sheet1!3", "Sheet1!6", "Sheet1!8"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("LoadReport().Quarantine ключи = %v, want %v", keys, want)
+	}
+	if want := []string{"x7", "Корпус", "2", "2", "", ""}; !reflect.DeepEqual(report.Quarantine[0].Cells, want) {
+		t.Errorf("Quarantine[0].Cells = %q, want %q", report.Quarantine[0].Cells, want)
+	}
+
+	// Строки карантина не пропадают при сохранении и читаются с листа карантина
+	if err := storage.Save(products); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := storage.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	reloaded := storage.LoadReport()
+	// Исправленные при сохранении значения больше не считаются проблемами
+	wantIssues = []LoadIssue{{Sheet: defaultProductsSheet, Row: 4, Column: "B", Value: "", Reason: "пустое наименование"}}
+	if !reflect.DeepEqual(reloaded.Issues, wantIssues) {
+		t.Errorf("LoadReport().Issues после сохранения = %v, want %v", reloaded.Issues, wantIssues)
+	}
+	if len(reloaded.Quarantine) != 3 || reloaded.Quarantine[0].Key != "Карантин!2" ||
+		reloaded.Quarantine[0].Reason != report.Quarantine[0].Reason ||
+		!reflect.DeepEqual(reloaded.Quarantine[0].Cells, report.Quarantine[0].Cells) {
+		t.Errorf("LoadReport().Quarantine после сохранения = %v, want строки %v", reloaded.Quarantine, report.Quarantine)
+	}
+
+	// Изменение копии отчета не меняет карантин хранилища
+	reloaded.Quarantine[0].Cells[1] = "Изменено"
+	if got := storage.LoadReport().Quarantine[0].Cells[1]; got != "Корпус" {
+		t.Errorf("Quarantine[0].Cells[1] = %q после изменения копии", got)
+	}
+
+	// Опустевший карантин удаляет лист
+	storage.SetQuarantine(nil)
+	if err := storage.Save(products); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if index, _ := storage.file.GetSheetIndex(quarantineSheet); index >= 0 {
+		t.Errorf("лист карантина остался после очистки")
+	}
+}
+
+func TestExcelStorage_QuarantineKeepsExtraColumns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writeRows(t, filename, [][]interface{}{
+		{"ID", "Наименование", "Время обработки в часах", "Расчет времени", "Примечание", "Цех"},
+		{1, "Вал", 1, "1", "основной", 1},
+		{"x2", "Корпус", 2, "2", "литье", 3},
+	})
+
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+	products, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := QuarantinedRow{
+		Cells:        []string{"x2", "Корпус", "2", "2", "", "", "литье", "3"},
+		ExtraHeaders: []string{"Примечание", "Цех"},
+	}
+	quarantine := storage.LoadReport().Quarantine
+	if len(quarantine) != 1 || !reflect.DeepEqual(quarantine[0].Cells, want.Cells) || !reflect.DeepEqual(quarantine[0].ExtraHeaders, want.ExtraHeaders) {
+		t.Fatalf("LoadReport().Quarantine = %+v, want строку %+v", quarantine, want)
+	}
+
+	// Дополнительные колонки сохраняются на листе карантина и читаются обратно
+	if err := storage.Save(products); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := storage.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	quarantine = storage.LoadReport().Quarantine
+	if len(quarantine) != 1 || quarantine[0].Key != "Карантин!2" ||
+		!reflect.DeepEqual(quarantine[0].Cells, want.Cells) || !reflect.DeepEqual(quarantine[0].ExtraHeaders, want.ExtraHeaders) {
+		t.Fatalf("LoadReport().Quarantine после сохранения = %+v, want строку %+v", quarantine, want)
+	}
+
+	// Колонки, которых больше нет ни у одной строки, удаляются с листа карантина
+	storage.SetQuarantine([]QuarantinedRow{{Cells: []string{"x3", "Втулка", "1", "1", "", ""}, Reason: "ID не является целым числом"}})
+	if err := storage.Save(products); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := storage.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	quarantine = storage.LoadReport().Quarantine
+	if len(quarantine) != 1 || len(quarantine[0].Cells) != productColumnCount || len(quarantine[0].ExtraHeaders) != 0 {
+		t.Errorf("LoadReport().Quarantine после замены = %+v, want строку без дополнительных колонок", quarantine)
+	}
+}
+
+func TestExcelStorage_ColumnMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		sheet   string // лист в файле
+		open    string // лист, заданный через WithSheet
+		aliases ColumnAliases
+		rows    [][]interface{}
+		want    models.Products
+		wantErr bool
+	}{
+		{
+			name: "колонки в другом порядке на английском",
+			rows: [][]interface{}{
+				{"Formula", "Name", "ID", "Processing time"},
+				{"1,5", "Вал", 1, 1.5},
+			},
+			want: models.Products{{ID: 1, Name: "Вал", ProcessingTime: 1.5, TimeCalculation: "1,5"}},
+		},
+		{
+			name:  "первый лист с другим именем",
+			sheet: "Реестр",
+			rows: [][]interface{}{
+				{" номер ", "НАЗВАНИЕ", "Формула"},
+				{2, "Корпус", "2"},
+			},
+			want: models.Products{{ID: 2, Name: "Корпус", TimeCalculation: "2"}},
+		},
+		{
+			name:  "лист из настроек",
+			sheet: "Детали",
+			open:  "Детали",
+			rows: [][]interface{}{
+				{"ID", "Наименование"},
+				{3, "Шайба"},
+			},
+			want: models.Products{{ID: 3, Name: "Шайба"}},
+		},
+		{
+			name:    "несуществующий лист из настроек",
+			open:    "Детали",
+			rows:    [][]interface{}{{"ID", "Наименование"}},
+			wantErr: true,
+		},
+		{
+			name:    "заголовки из настроек",
+			aliases: ColumnAliases{ColumnID: {"Артикул"}, ColumnTimeCalculation: {"Norm"}},
+			rows: [][]interface{}{
+				{"Артикул", "Name", "Norm"},
+				{4, "Гайка", "30m"},
+			},
+			want: models.Products{{ID: 4, Name: "Гайка", TimeCalculation: "30m"}},
+		},
+		{
+			name: "порядковый номер не считается ID",
+			rows: [][]interface{}{
+				{"№", "Код", "ID", "Наименование"},
+				{1, "А-15", 10, "Вал"},
+			},
+			want: models.Products{{ID: 10, Name: "Вал"}},
+		},
+		{
+			name: "две колонки одного поля",
+			rows: [][]interface{}{{"Номер", "ID", "Наименование"}, {1, 2, "Вал"}},
+			want: models.Products{{ID: 2, Name: "Вал"}},
+		},
+		{
+			name: "лишняя колонка Время",
+			rows: [][]interface{}{
+				{"ID", "Наименование", "Время", "Время обработки"},
+				{1, "Вал", "утро", 2.5},
+			},
+			want: models.Products{{ID: 1, Name: "Вал", ProcessingTime: 2.5}},
+		},
+		{
+			name: "две колонки с одинаковым заголовком",
+			rows: [][]interface{}{{"ID", "Наименование", "Время", "Время"}, {1, "Вал", 3, 4}},
+			want: models.Products{{ID: 1, Name: "Вал", ProcessingTime: 3}},
+		},
+		{
+			name:    "колонка из настроек и колонка по умолчанию",
+			aliases: ColumnAliases{ColumnID: {"Артикул"}},
+			rows:    [][]interface{}{{"ID", "Артикул", "Наименование"}, {5, 4, "Гайка"}},
+			want:    models.Products{{ID: 4, Name: "Гайка"}},
+		},
+		{
+			name:    "нет колонки наименования",
+			rows:    [][]interface{}{{"ID", "Формула"}, {1, "2"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "test.xlsx")
+			sheet := tt.sheet
+			if sheet == "" {
+				sheet = defaultProductsSheet
+			}
+			writeSheetRows(t, filename, sheet, tt.rows)
+
+			storage := NewExcelStorage().WithFilename(filename).WithSheet(tt.open).WithColumnAliases(tt.aliases)
+			defer storage.Close()
+			products, err := storage.Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(products, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", products, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcelStorage_SavePreservesColumns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writeSheetRows(t, filename, "Реестр", [][]interface{}{
+		{"Цех", "Name", "ID", "Formula", "", "Примечание"},
+		{"Токарный", "Вал", 1, "1", "", "срочно"},
+		{"Сборочный", "Корпус", 2, "2", "x"},
+	})
+
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+	products, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	products[1].Name = "Корпус 2"
+	products = append(products, models.Product{ID: 3, Name: "Крышка", ProcessingTime: 0.5, TimeCalculation: "30m", Version: 1})
+	if err := storage.Save(products); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	file, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer file.Close()
+	rows, err := file.GetRows("Реестр")
+	if err != nil {
+		t.Fatalf("GetRows() error = %v", err)
+	}
+
+	// Колонки остаются на своих местах, недостающие добавляются в конец
+	want := [][]string{
+		{"Цех", "Name", "ID", "Formula", "", "Примечание", "Время обработки в часах", "Версия", "Изменено"},
+		{"Токарный", "Вал", "1", "1", "", "срочно", "0", "0"},
+		{"Сборочный", "Корпус 2", "2", "2", "x", "", "0", "0"},
+		{"", "Крышка", "3", "30m", "", "", "0.5", "1"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("GetRows() после Save = %q, want %q", rows, want)
+	}
+}
+
+func TestExcelStorage_FailedSaveKeepsExtraColumns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writeRows(t, filename, [][]interface{}{
+		{"ID", "Наименование", "Примечание"},
+		{1, "Вал", "срочно"},
+		{2, "Корпус", "до пятницы"},
+	})
+
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+	products, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Удаление не сохранено, и приложение возвращает прежний список
+	orig := writeWorkbook
+	writeWorkbook = func(*excelize.File, io.Writer) error { return errors.New("сбой записи") }
+	err = storage.Save(products[1:])
+	writeWorkbook = orig
+	if err == nil {
+		t.Fatalf("Save() должен вернуть ошибку при сбое записи")
+	}
+	if err := storage.Save(products); err != nil {
+		t.Fatalf("Save() после сбоя error = %v", err)
+	}
+
+	file, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer file.Close()
+	for cell, want := range map[string]string{"C2": "срочно", "C3": "до пятницы"} {
+		if got, _ := file.GetCellValue(defaultProductsSheet, cell); got != want {
+			t.Errorf("%s = %q, want %q", cell, got, want)
+		}
+	}
+}
+
+func TestExcelStorage_FailedSaveIsDiscarded(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writeRows(t, filename, [][]interface{}{
+		{"ID", "Наименование"},
+		{1, "Вал"},
+	})
+
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+	products, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	orig := writeWorkbook
+	writeWorkbook = func(*excelize.File, io.Writer) error { return errors.New("сбой записи") }
+	err = storage.Save(append(products, models.Product{ID: 2, Name: "Корпус"}))
+	writeWorkbook = orig
+	if err == nil {
+		t.Fatalf("Save() должен вернуть ошибку при сбое записи")
+	}
+
+	// Сохранение констант не должно записать продукт, добавление которого отменено
+	if err := storage.SaveConstants(models.Constants{{Name: "k", Value: 2}}); err != nil {
+		t.Fatalf("SaveConstants() error = %v", err)
+	}
+
+	loaded, err := NewExcelStorage().WithFilename(filename).Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(loaded) != 1 || loaded[0].Name != "Вал" {
+		t.Errorf("Load() = %+v, want только продукт Вал", loaded)
+	}
+}
+
+func TestExcelStorage_SaveKeepsWorkbook(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	file := excelize.NewFile()
+	rows := [][]interface{}{
+		{"ID", "Наименование", "Время обработки в часах", "Расчет времени", "Версия", "Изменено", "Итого"},
+		{1, "Вал", 1, "1", 1},
+		{2, "Корпус", 2, "2", 1},
+		{3, "Крышка", 3, "3", 1},
+	}
+	for i, row := range rows {
+		if err := file.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("SetSheetRow() error = %v", err)
+		}
+	}
+	if err := file.SetCellFormula("Sheet1", "G2", "C2*2"); err != nil {
+		t.Fatalf("SetCellFormula() error = %v", err)
+	}
+	style, err := file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		t.Fatalf("NewStyle() error = %v", err)
+	}
+	if err := file.SetCellStyle("Sheet1", "A1", "G1", style); err != nil {
+		t.Fatalf("SetCellStyle() error = %v", err)
+	}
+	if err := file.SetColWidth("Sheet1", "B", "B", 40); err != nil {
+		t.Fatalf("SetColWidth() error = %v", err)
+	}
+	if err := file.SetPanes("Sheet1", &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		t.Fatalf("SetPanes() error = %v", err)
+	}
+	if _, err := file.NewSheet("План"); err != nil {
+		t.Fatalf("NewSheet() error = %v", err)
+	}
+	if err := file.SetCellValue("План", "A1", "загрузка цеха"); err != nil {
+		t.Fatalf("SetCellValue() error = %v", err)
+	}
+	if err := file.SaveAs(filename); err != nil {
+		t.Fatalf("SaveAs() error = %v", err)
+	}
+	file.Close()
+
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+	products, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	// Первая запись остается на месте, последняя удаляется
+	products[0].Name = "Вал длинный"
+	products = products[:2]
+	if err := storage.Save(products); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	saved, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer saved.Close()
+
+	if got, _ := saved.GetCellStyle("Sheet1", "B1"); got != style {
+		t.Errorf("стиль заголовка = %d, want %d", got, style)
+	}
+	if got, _ := saved.GetColWidth("Sheet1", "B"); got != 40 {
+		t.Errorf("ширина колонки B = %v, want 40", got)
+	}
+	if panes, err := saved.GetPanes("Sheet1"); err != nil || !panes.Freeze || panes.YSplit != 1 {
+		t.Errorf("GetPanes() = %+v, %v, want закрепленную строку заголовков", panes, err)
+	}
+	if got, _ := saved.GetCellValue("План", "A1"); got != "загрузка цеха" {
+		t.Errorf("лист План A1 = %q, want %q", got, "загрузка цеха")
+	}
+	if got, _ := saved.GetCellFormula("Sheet1", "G2"); got != "C2*2" {
+		t.Errorf("формула G2 = %q, want C2*2", got)
+	}
+	if got, _ := saved.GetCellValue("Sheet1", "B2"); got != "Вал длинный" {
+		t.Errorf("B2 = %q, want %q", got, "Вал длинный")
+	}
+	// Строка удаленной записи очищена
+	if got, _ := saved.GetCellValue("Sheet1", "A4"); got != "" {
+		t.Errorf("A4 = %q, want пустую ячейку", got)
+	}
+
+	reloaded, err := storage.Load()
+	if err != nil || len(reloaded) != 2 {
+		t.Errorf("Load() после Save = %v, %v, want 2 записи", reloaded, err)
+	}
+}
+
+func TestExcelStorage_NewFileFreezesHeader(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	storage := NewExcelStorage().WithFilename(filename).WithSheet("Реестр")
+	defer storage.Close()
+	if _, err := storage.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	file, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer file.Close()
+	if panes, err := file.GetPanes("Реестр"); err != nil || !panes.Freeze || panes.YSplit != 1 {
+		t.Errorf("GetPanes() = %+v, %v, want закрепленную строку заголовков", panes, err)
+	}
+	if got, _ := file.GetCellValue("Реестр", "B1"); got != "Наименование" {
+		t.Errorf("B1 = %q, want Наименование", got)
+	}
+}
@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLocked возвращается, если база данных открыта для записи другой копией приложения
+var ErrLocked = errors.New("база данных открыта другой копией приложения")
+
+// lockStaleAfter время без обновления, после которого блокировка считается брошенной
+const lockStaleAfter = 5 * time.Minute
+
+// lockRefreshInterval период обновления времени в файле блокировки
+const lockRefreshInterval = time.Minute
+
+// LockInfo содержимое файла блокировки: кто и когда открыл базу данных
+type LockInfo struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// LockedError возвращается, если файл блокировки принадлежит другой копии приложения
+type LockedError struct {
+	Holder LockInfo
+}
+
+// Error возвращает описание блокировки
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v: компьютер %s, PID %d, с %s", ErrLocked, e.Holder.Host, e.Holder.PID,
+		e.Holder.CreatedAt.Local().Format("02.01.2006 15:04"))
+}
+
+// Unwrap позволяет проверять ошибку через errors.Is(err, ErrLocked)
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// FileLock рекомендательная блокировка базы данных файлом <база>.lock рядом с ней.
+// Пока блокировка удерживается, время в файле периодически обновляется, поэтому
+// блокировку упавшего процесса или компьютера можно распознать и забрать.
+type FileLock struct {
+	mu   sync.Mutex
+	path string
+	info LockInfo
+	held bool
+	stop chan struct{}
+	done chan struct{}
+	now  func() time.Time
+	// lost блокировка, которая забрала эту, пока она удерживалась;
+	// записывается при обновлении файла блокировки
+	lost atomic.Pointer[LockedError]
+}
+
+// NewFileLock создает блокировку для файла базы данных
+func NewFileLock(filename string) *FileLock {
+	return &FileLock{
+		path: filename + ".lock",
+		now:  time.Now,
+	}
+}
+
+// Acquire берет блокировку. Брошенная блокировка забирается, а если блокировку
+// удерживает другая копия приложения, возвращается *LockedError.
+// Блокировку, которую забрала другая копия, Acquire пробует взять заново.
+func (fl *FileLock) Acquire() error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	if fl.held {
+		if fl.lost.Load() == nil {
+			return nil
+		}
+		fl.stopRefresh()
+	}
+
+	host, _ := os.Hostname()
+	now := fl.now().UTC()
+	fl.info = LockInfo{PID: os.Getpid(), Host: host, CreatedAt: now, UpdatedAt: now}
+
+	for attempt := 0; ; attempt++ {
+		err := createLockFile(fl.path, fl.info)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("ошибка при создании файла блокировки: %w", err)
+		}
+
+		data, err := os.ReadFile(fl.path)
+		if errors.Is(err, os.ErrNotExist) {
+			// Блокировку только что сняли, пробуем еще раз
+			continue
+		}
+		var holder LockInfo
+		if err == nil {
+			holder, err = parseLockInfo(data)
+		}
+		stale := fl.stale(holder)
+		if err != nil {
+			// Файл может быть поврежден или еще записываться другой копией
+			stale = fl.abandoned()
+		}
+		if attempt > 0 || !stale {
+			return &LockedError{Holder: holder}
+		}
+		// Брошенный файл забирается переименованием, после чего новый файл
+		// создается так же, как обычно: если другая копия успеет раньше,
+		// создание не удастся и ее блокировка останется на месте
+		if err := claimLockFile(fl.path, data); err != nil {
+			return fmt.Errorf("ошибка при замене брошенной блокировки: %w", err)
+		}
+	}
+
+	fl.held = true
+	fl.lost.Store(nil)
+	fl.stop = make(chan struct{})
+	fl.done = make(chan struct{})
+	go fl.refresh(fl.stop, fl.done)
+	return nil
+}
+
+// Release снимает блокировку, если она удерживается и файл блокировки все еще наш
+func (fl *FileLock) Release() error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	if !fl.held {
+		return nil
+	}
+	fl.stopRefresh()
+
+	holder, err := readLockInfo(fl.path)
+	if err != nil || !fl.owns(holder) {
+		return nil
+	}
+	if err := os.Remove(fl.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("ошибка при удалении файла блокировки: %w", err)
+	}
+	return nil
+}
+
+// Lost возвращает *LockedError, если блокировку забрала другая копия
+// приложения, пока она удерживалась, иначе nil
+func (fl *FileLock) Lost() error {
+	if lost := fl.lost.Load(); lost != nil {
+		return lost
+	}
+	return nil
+}
+
+// stopRefresh останавливает обновление файла блокировки и отмечает ее снятой
+func (fl *FileLock) stopRefresh() {
+	close(fl.stop)
+	<-fl.done
+	fl.held = false
+	fl.lost.Store(nil)
+}
+
+// refresh периодически обновляет время в файле блокировки, пока не закрыт stop
+func (fl *FileLock) refresh(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !fl.touch() {
+				return
+			}
+		}
+	}
+}
+
+// touch обновляет время в файле блокировки и сообщает, удерживается ли она.
+// Если файл принадлежит другой копии, блокировка отмечается потерянной.
+func (fl *FileLock) touch() bool {
+	holder, err := readLockInfo(fl.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Файл удалили, но база никем не занята: блокировка создается заново
+		info := fl.info
+		info.UpdatedAt = fl.now().UTC()
+		createLockFile(fl.path, info)
+		return true
+	}
+	if err != nil {
+		// Файл может еще записываться другой копией, он проверится в следующий раз
+		return true
+	}
+	if !fl.owns(holder) {
+		fl.lost.Store(&LockedError{Holder: holder})
+		return false
+	}
+	holder.UpdatedAt = fl.now().UTC()
+	writeFileAtomic(fl.path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(holder)
+	})
+	return true
+}
+
+// owns сообщает, принадлежит ли блокировка этому экземпляру
+func (fl *FileLock) owns(holder LockInfo) bool {
+	return holder.PID == fl.info.PID && holder.Host == fl.info.Host && holder.CreatedAt.Equal(fl.info.CreatedAt)
+}
+
+// stale сообщает, брошена ли блокировка: ее процесс на этом компьютере завершился
+// или время в файле давно не обновлялось
+func (fl *FileLock) stale(holder LockInfo) bool {
+	host, _ := os.Hostname()
+	if holder.Host == host && holder.PID != os.Getpid() && !processAlive(holder.PID) {
+		return true
+	}
+	return fl.now().Sub(holder.UpdatedAt) > lockStaleAfter
+}
+
+// abandoned сообщает, что файл блокировки давно не изменялся
+func (fl *FileLock) abandoned() bool {
+	info, err := os.Stat(fl.path)
+	return err == nil && fl.now().Sub(info.ModTime()) > lockStaleAfter
+}
+
+// createLockFile создает файл блокировки, только если его еще нет
+func createLockFile(path string, info LockInfo) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(info); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// claimLockFile забирает брошенный файл блокировки с содержимым stale,
+// переименовывая его: один и тот же файл может переименовать только одна копия.
+// Если на месте брошенного файла уже оказался новый файл другой копии,
+// он возвращается обратно. Вынесена в переменную, чтобы тесты могли
+// имитировать другую копию, забравшую блокировку одновременно.
+var claimLockFile = func(path string, stale []byte) error {
+	claimed := fmt.Sprintf("%s.%d-%d.stale", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, claimed); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Брошенный файл уже забрала другая копия
+			return nil
+		}
+		return err
+	}
+	if data, err := os.ReadFile(claimed); err == nil && !bytes.Equal(data, stale) {
+		// Link не заменяет существующий файл, в отличие от Rename
+		os.Link(claimed, path)
+	}
+	os.Remove(claimed)
+	return nil
+}
+
+// readLockInfo читает содержимое файла блокировки
+func readLockInfo(path string) (LockInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return LockInfo{}, err
+	}
+	return parseLockInfo(data)
+}
+
+// parseLockInfo разбирает содержимое файла блокировки
+func parseLockInfo(data []byte) (LockInfo, error) {
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("поврежденный файл блокировки: %w", err)
+	}
+	return info, nil
+}
+
+// dbLock блокировка базы данных внутри хранилища: берется при Load, снимается
+// при Close и запрещает запись, пока базу удерживает другая копия приложения
+type dbLock struct {
+	enabled bool
+	lock    *FileLock
+	err     error
+}
+
+// acquire берет блокировку файла. Если базу удерживает другая копия,
+// ошибка запоминается и возвращается при попытке записи.
+func (dl *dbLock) acquire(filename string) error {
+	if !dl.enabled {
+		return nil
+	}
+	if dl.lock == nil || dl.lock.path != filename+".lock" {
+		if err := dl.release(); err != nil {
+			return err
+		}
+		dl.lock = NewFileLock(filename)
+	}
+
+	err := dl.lock.Acquire()
+	var locked *LockedError
+	if errors.As(err, &locked) {
+		dl.err = err
+		return nil
+	}
+	dl.err = nil
+	return err
+}
+
+// writable возвращает ошибку, если запись запрещена блокировкой другой копии,
+// в том числе если другая копия забрала блокировку после загрузки
+func (dl *dbLock) writable() error {
+	if dl.err != nil || dl.lock == nil {
+		return dl.err
+	}
+	return dl.lock.Lost()
+}
+
+// release снимает блокировку
+func (dl *dbLock) release() error {
+	dl.err = nil
+	if dl.lock == nil {
+		return nil
+	}
+	return dl.lock.Release()
+}
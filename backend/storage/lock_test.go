@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+)
+
+// writeLockFile записывает файл блокировки от имени другой копии приложения
+func writeLockFile(t *testing.T, filename string, info LockInfo) {
+	t.Helper()
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if err := os.WriteFile(filename+".lock", data, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+// deadPID возвращает PID завершившегося процесса
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestFileLock_AcquireAndRelease(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+
+	first := NewFileLock(filename)
+	if err := first.Acquire(); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	info, err := readLockInfo(filename + ".lock")
+	if err != nil {
+		t.Fatalf("readLockInfo() error = %v", err)
+	}
+	host, _ := os.Hostname()
+	if info.PID != os.Getpid() || info.Host != host || info.CreatedAt.IsZero() {
+		t.Errorf("Файл блокировки = %+v, want PID %d и компьютер %s", info, os.Getpid(), host)
+	}
+
+	// Вторая блокировка того же файла отклоняется с описанием владельца
+	second := NewFileLock(filename)
+	err = second.Acquire()
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrLocked) {
+		t.Fatalf("Acquire() второй блокировки error = %v, want *LockedError", err)
+	}
+	if !reflect.DeepEqual(locked.Holder, info) {
+		t.Errorf("LockedError.Holder = %+v, want %+v", locked.Holder, info)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, err := os.Stat(filename + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("После Release() файл блокировки остался: %v", err)
+	}
+	if err := second.Acquire(); err != nil {
+		t.Errorf("Acquire() после Release() error = %v", err)
+	}
+	second.Release()
+}
+
+func TestFileLock_Stale(t *testing.T) {
+	host, _ := os.Hostname()
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name  string
+		info  LockInfo
+		stale bool
+	}{
+		{
+			name:  "Процесс на этом компьютере завершился",
+			info:  LockInfo{PID: deadPID(t), Host: host, CreatedAt: now, UpdatedAt: now},
+			stale: true,
+		},
+		{
+			name:  "Другой компьютер давно не обновлял блокировку",
+			info:  LockInfo{PID: 1, Host: "other-host", CreatedAt: now.Add(-time.Hour), UpdatedAt: now.Add(-time.Hour)},
+			stale: true,
+		},
+		{
+			name:  "Другой компьютер работает",
+			info:  LockInfo{PID: 1, Host: "other-host", CreatedAt: now.Add(-time.Hour), UpdatedAt: now.Add(-time.Minute)},
+			stale: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "database.xlsx")
+			writeLockFile(t, filename, tt.info)
+
+			lock := NewFileLock(filename)
+			err := lock.Acquire()
+			defer lock.Release()
+			if tt.stale && err != nil {
+				t.Errorf("Acquire() брошенной блокировки error = %v", err)
+			}
+			if !tt.stale && !errors.Is(err, ErrLocked) {
+				t.Errorf("Acquire() действующей блокировки error = %v, want %v", err, ErrLocked)
+			}
+		})
+	}
+}
+
+func TestFileLock_StaleTakeoverRace(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	old := time.Now().UTC().Add(-time.Hour)
+	writeLockFile(t, filename, LockInfo{PID: 1, Host: "other-host", CreatedAt: old, UpdatedAt: old})
+
+	// Другая копия забирает брошенную блокировку раньше этой, но после того,
+	// как эта прочитала файл
+	now := time.Now().UTC()
+	rival := LockInfo{PID: 2, Host: "rival-host", CreatedAt: now, UpdatedAt: now}
+	defer func(orig func(string, []byte) error) { claimLockFile = orig }(claimLockFile)
+	orig := claimLockFile
+	claimLockFile = func(path string, stale []byte) error {
+		writeLockFile(t, filename, rival)
+		return orig(path, stale)
+	}
+
+	lock := NewFileLock(filename)
+	err := lock.Acquire()
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Holder.Host != rival.Host || locked.Holder.PID != rival.PID {
+		t.Fatalf("Acquire() error = %v, want блокировку %s", err, rival.Host)
+	}
+
+	// Чужая блокировка остается на месте
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if holder, err := readLockInfo(filename + ".lock"); err != nil || holder.Host != rival.Host {
+		t.Errorf("Файл блокировки = %+v, %v, want блокировку %s", holder, err, rival.Host)
+	}
+	if matches, _ := filepath.Glob(filename + ".lock.*"); len(matches) != 0 {
+		t.Errorf("Остались временные файлы блокировки: %v", matches)
+	}
+}
+
+func TestFileLock_Lost(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	lock := NewFileLock(filename)
+	if err := lock.Acquire(); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer lock.Release()
+
+	// Удаленный файл создается заново при обновлении
+	if err := os.Remove(filename + ".lock"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if !lock.touch() || lock.Lost() != nil {
+		t.Fatalf("touch() после удаления файла: блокировка потеряна: %v", lock.Lost())
+	}
+	if holder, err := readLockInfo(filename + ".lock"); err != nil || !lock.owns(holder) {
+		t.Errorf("Файл блокировки = %+v, %v, want файл этой блокировки", holder, err)
+	}
+
+	// Другая копия забрала блокировку, например посчитав ее брошенной
+	now := time.Now().UTC()
+	rival := LockInfo{PID: 2, Host: "rival-host", CreatedAt: now, UpdatedAt: now}
+	writeLockFile(t, filename, rival)
+	if lock.touch() {
+		t.Fatalf("touch() чужой блокировки = true")
+	}
+	var locked *LockedError
+	if err := lock.Lost(); !errors.As(err, &locked) || locked.Holder.Host != rival.Host {
+		t.Fatalf("Lost() = %v, want блокировку %s", err, rival.Host)
+	}
+
+	// Повторная попытка взять блокировку видит действующую чужую
+	if err := lock.Acquire(); !errors.As(err, &locked) || locked.Holder.Host != rival.Host {
+		t.Errorf("Acquire() после потери error = %v, want блокировку %s", err, rival.Host)
+	}
+}
+
+func TestExcelStorage_LockLost(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	storage := NewExcelStorage().WithFilename(filename).WithLock()
+	defer storage.Close()
+	if _, err := storage.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	now := time.Now().UTC()
+	writeLockFile(t, filename, LockInfo{PID: 2, Host: "rival-host", CreatedAt: now, UpdatedAt: now})
+	storage.lock.lock.touch()
+
+	if err := storage.LockError(); !errors.Is(err, ErrLocked) {
+		t.Errorf("LockError() = %v, want %v", err, ErrLocked)
+	}
+	if err := storage.Save(models.Products{{ID: 1, Name: "Вал"}}); !errors.Is(err, ErrLocked) {
+		t.Errorf("Save() error = %v, want %v", err, ErrLocked)
+	}
+}
+
+func TestExcelStorage_Lock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "database.xlsx")
+	testProducts := models.Products{{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1"}}
+
+	first := NewExcelStorage().WithFilename(filename).WithLock()
+	if _, err := first.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := first.Save(testProducts); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// Вторая копия читает данные, но не может их сохранить
+	second := NewExcelStorage().WithFilename(filename).WithLock()
+	defer second.Close()
+	products, err := second.Load()
+	if err != nil {
+		t.Fatalf("Load() второй копией error = %v", err)
+	}
+	if !reflect.DeepEqual(products, testProducts) {
+		t.Errorf("Load() второй копией = %v, want %v", products, testProducts)
+	}
+	if err := second.LockError(); !errors.Is(err, ErrLocked) {
+		t.Errorf("LockError() = %v, want %v", err, ErrLocked)
+	}
+	if err := second.Save(nil); !errors.Is(err, ErrLocked) {
+		t.Errorf("Save() второй копией error = %v, want %v", err, ErrLocked)
+	}
+
+	// После закрытия первой копии вторая получает блокировку при следующей загрузке
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := second.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := second.LockError(); err != nil {
+		t.Errorf("LockError() после закрытия первой копии = %v", err)
+	}
+	if err := second.Save(testProducts); err != nil {
+		t.Errorf("Save() error = %v", err)
+	}
+}
+
+func TestSQLiteStorage_Lock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.db")
+
+	first := NewSQLiteStorage().WithFilename(filename).WithLock()
+	defer first.Close()
+	if _, err := first.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	second := NewSQLiteStorage().WithFilename(filename).WithLock()
+	defer second.Close()
+	if _, err := second.Load(); err != nil {
+		t.Fatalf("Load() второй копией error = %v", err)
+	}
+	if err := second.Insert(models.Product{ID: 1, Name: "Продукт"}); !errors.Is(err, ErrLocked) {
+		t.Errorf("Insert() второй копией error = %v, want %v", err, ErrLocked)
+	}
+	if err := first.Insert(models.Product{ID: 1, Name: "Продукт"}); err != nil {
+		t.Errorf("Insert() error = %v", err)
+	}
+}
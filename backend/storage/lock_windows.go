@@ -0,0 +1,16 @@
+//go:build windows
+
+package storage
+
+import "os"
+
+// processAlive сообщает, работает ли процесс с указанным PID.
+// В Windows FindProcess открывает процесс и возвращает ошибку, если его нет.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// SchemaVersion версия схемы Excel файла, которую записывает приложение.
+// При изменении формата файла версия увеличивается и добавляется миграция.
+const SchemaVersion = 3
+
+// metaSheet скрытый лист с метаданными книги
+const metaSheet = "_meta"
+
+// schemaVersionKey ключ версии схемы на листе метаданных
+const schemaVersionKey = "schemaVersion"
+
+// ErrNewerSchema возвращается при загрузке файла, созданного более новой
+// версией приложения: старая версия могла бы повредить его при сохранении
+var ErrNewerSchema = errors.New("файл создан более новой версией приложения")
+
+// migration переводит книгу с предыдущей версии схемы на версию version
+type migration struct {
+	version     int
+	description string
+	apply       func(sheet productSheet) error
+}
+
+// productSheet лист с продуктами, который изменяет миграция
+type productSheet struct {
+	file   *excelize.File
+	layout columnLayout
+	rows   [][]string
+}
+
+// migrations миграции по возрастанию версий. Файлы без листа метаданных
+// считаются файлами версии 1: они созданы до появления версий схемы.
+var migrations = []migration{
+	{version: 2, description: "колонки версии и времени изменения продукта", apply: addVersionColumns},
+	{version: 3, description: "время обработки записывается числом", apply: numericProcessingTimes},
+}
+
+// migrateWorkbook по шагам переводит книгу на текущую версию схемы и записывает
+// ее на лист метаданных. Изменения попадают в файл при следующем сохранении.
+// Возвращает версию схемы, с которой был прочитан файл.
+func migrateWorkbook(file *excelize.File, sheet string, aliases ColumnAliases) (int, error) {
+	version, err := readSchemaVersion(file)
+	if err != nil {
+		return 0, err
+	}
+	if version > SchemaVersion {
+		return version, fmt.Errorf("%w: версия схемы %d, поддерживается %d", ErrNewerSchema, version, SchemaVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		// Каждая миграция видит лист после предыдущей
+		products, err := readProductSheet(file, sheet, aliases)
+		if err != nil {
+			return version, err
+		}
+		if err := m.apply(products); err != nil {
+			return version, fmt.Errorf("ошибка миграции на версию схемы %d (%s): %w", m.version, m.description, err)
+		}
+	}
+
+	return version, writeSchemaVersion(file)
+}
+
+// readProductSheet читает лист с продуктами и расположение колонок на нем
+func readProductSheet(file *excelize.File, sheet string, aliases ColumnAliases) (productSheet, error) {
+	rows, err := file.GetRows(sheet)
+	if err != nil {
+		return productSheet{}, fmt.Errorf("ошибка при чтении строк: %w", err)
+	}
+	layout, err := readLayout(sheet, rows, aliases)
+	if err != nil {
+		return productSheet{}, err
+	}
+	return productSheet{file: file, layout: layout, rows: rows}, nil
+}
+
+// readSchemaVersion читает версию схемы с листа метаданных.
+// Файл без листа метаданных считается файлом версии 1.
+func readSchemaVersion(file *excelize.File) (int, error) {
+	if index, err := file.GetSheetIndex(metaSheet); err != nil || index < 0 {
+		return 1, nil
+	}
+
+	rows, err := file.GetRows(metaSheet)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при чтении метаданных: %w", err)
+	}
+	for _, row := range rows {
+		if len(row) < 2 || row[0] != schemaVersionKey {
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimSpace(row[1]))
+		if err != nil || version < 1 {
+			return 0, fmt.Errorf("некорректная версия схемы %q", row[1])
+		}
+		return version, nil
+	}
+	return 1, nil
+}
+
+// writeSchemaVersion записывает текущую версию схемы на скрытый лист метаданных
+func writeSchemaVersion(file *excelize.File) error {
+	if _, err := prepareSheet(file, metaSheet, true); err != nil {
+		return err
+	}
+	row := []interface{}{schemaVersionKey, SchemaVersion}
+	if err := file.SetSheetRow(metaSheet, "A1", &row); err != nil {
+		return fmt.Errorf("ошибка при записи версии схемы: %w", err)
+	}
+	if err := file.SetSheetVisible(metaSheet, false); err != nil {
+		return fmt.Errorf("ошибка при скрытии листа метаданных: %w", err)
+	}
+	return nil
+}
+
+// addVersionColumns добавляет колонки версии и времени изменения после
+// последней заполненной колонки, если их нет, и присваивает версию 1
+// продуктам без версии, как продуктам, добавленным в новый файл
+func addVersionColumns(sheet productSheet) error {
+	if len(sheet.rows) == 0 {
+		return nil
+	}
+
+	header := sheet.rows[0]
+	next := 0
+	for _, row := range sheet.rows {
+		next = max(next, len(row))
+	}
+	columns := make(map[string]int)
+	for _, field := range []string{ColumnVersion, ColumnUpdatedAt} {
+		if column := sheet.layout.index(field); column < len(header) {
+			columns[field] = column
+			continue
+		}
+		if err := sheet.setCell(next, 1, productHeaders[field]); err != nil {
+			return err
+		}
+		columns[field] = next
+		next++
+	}
+
+	id, version := sheet.layout.index(ColumnID), columns[ColumnVersion]
+	for i, row := range sheet.rows[1:] {
+		if strings.TrimSpace(cellAt(row, id)) == "" || strings.TrimSpace(cellAt(row, version)) != "" {
+			continue
+		}
+		if err := sheet.setCell(version, i+2, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// numericProcessingTimes заменяет время обработки, записанное текстом,
+// например с десятичной запятой, числом, чтобы по колонке работали формулы Excel.
+// Текст, который не является числом, остается как есть.
+func numericProcessingTimes(sheet productSheet) error {
+	if len(sheet.rows) == 0 {
+		return nil
+	}
+
+	column := sheet.layout.index(ColumnProcessingTime)
+	for i, row := range sheet.rows[1:] {
+		value := strings.TrimSpace(cellAt(row, column))
+		if value == "" {
+			continue
+		}
+		cell, err := excelize.CoordinatesToCellName(column+1, i+2)
+		if err != nil {
+			return err
+		}
+		cellType, err := sheet.file.GetCellType(sheet.layout.sheet, cell)
+		if err != nil {
+			return fmt.Errorf("ошибка при чтении ячейки %s: %w", cell, err)
+		}
+		if cellType != excelize.CellTypeSharedString && cellType != excelize.CellTypeInlineString {
+			continue
+		}
+		hours, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+		if err != nil {
+			continue
+		}
+		if err := sheet.setCell(column, i+2, hours); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCell записывает значение в ячейку листа с продуктами
+func (s productSheet) setCell(column, row int, value interface{}) error {
+	return setCell(s.file, s.layout.sheet, column, row, value)
+}
+
+// cellAt возвращает значение колонки column строки или пустую строку,
+// если excelize не вернул пустые ячейки в конце строки
+func cellAt(row []string, column int) string {
+	if column < 0 || column >= len(row) {
+		return ""
+	}
+	return row[column]
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// copyFixture копирует файл из testdata во временную директорию
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return filename
+}
+
+// applyMigration применяет одну миграцию к файлу из testdata и возвращает
+// строки листа с продуктами после нее
+func applyMigration(t *testing.T, fixture string, apply func(productSheet) error) (*excelize.File, [][]string) {
+	t.Helper()
+	file, err := excelize.OpenFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	sheet, err := readProductSheet(file, defaultProductsSheet, nil)
+	if err != nil {
+		t.Fatalf("readProductSheet() error = %v", err)
+	}
+	if err := apply(sheet); err != nil {
+		t.Fatalf("миграция error = %v", err)
+	}
+	rows, err := file.GetRows(defaultProductsSheet)
+	if err != nil {
+		t.Fatalf("GetRows() error = %v", err)
+	}
+	return file, rows
+}
+
+func TestMigrations_Order(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+2 {
+			t.Errorf("migrations[%d].version = %d, want %d", i, m.version, i+2)
+		}
+	}
+	if last := migrations[len(migrations)-1].version; last != SchemaVersion {
+		t.Errorf("последняя миграция на версию %d, want SchemaVersion %d", last, SchemaVersion)
+	}
+}
+
+func TestMigration_AddVersionColumns(t *testing.T) {
+	_, rows := applyMigration(t, "schema_v1.xlsx", addVersionColumns)
+
+	want := [][]string{
+		{"ID", "Наименование", "Время обработки в часах", "Расчет времени", "Версия", "Изменено"},
+		{"1", "Вал", "1.5", "1,5", "1"},
+		{"2", "Корпус", "2,5", "2,5", "1"},
+		{"3", "Сборка", "4", "#1 + #2", "1"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("GetRows() = %q, want %q", rows, want)
+	}
+}
+
+func TestMigration_NumericProcessingTimes(t *testing.T) {
+	file, rows := applyMigration(t, "schema_v2.xlsx", numericProcessingTimes)
+
+	wantTimes := []string{"1.5", "2.5", "0.5"}
+	for i, want := range wantTimes {
+		cell, _ := excelize.CoordinatesToCellName(3, i+2)
+		if got := rows[i+1][2]; got != want {
+			t.Errorf("%s = %q, want %q", cell, got, want)
+		}
+		cellType, err := file.GetCellType(defaultProductsSheet, cell)
+		if err != nil {
+			t.Fatalf("GetCellType() error = %v", err)
+		}
+		if cellType == excelize.CellTypeSharedString || cellType == excelize.CellTypeInlineString {
+			t.Errorf("%s записано текстом, want числом", cell)
+		}
+	}
+	// Остальные колонки не изменяются
+	if rows[2][3] != "2,5" || rows[1][4] != "3" {
+		t.Errorf("GetRows() = %q, изменены другие колонки", rows)
+	}
+}
+
+func TestExcelStorage_LoadMigratesOldFiles(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    models.Products
+	}{
+		{
+			fixture: "schema_v1.xlsx",
+			want: models.Products{
+				{ID: 1, Name: "Вал", ProcessingTime: 1.5, TimeCalculation: "1,5", Version: 1},
+				{ID: 2, Name: "Корпус", ProcessingTime: 2.5, TimeCalculation: "2,5", Version: 1},
+				{ID: 3, Name: "Сборка", ProcessingTime: 4, TimeCalculation: "#1 + #2", Version: 1},
+			},
+		},
+		{
+			fixture: "schema_v2.xlsx",
+			want: models.Products{
+				{ID: 1, Name: "Вал", ProcessingTime: 1.5, TimeCalculation: "1,5", Version: 3, UpdatedAt: time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)},
+				{ID: 2, Name: "Корпус", ProcessingTime: 2.5, TimeCalculation: "2,5", Version: 1},
+				{ID: 3, Name: "Крышка", ProcessingTime: 0.5, TimeCalculation: "30m", Version: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			filename := copyFixture(t, tt.fixture)
+			storage := NewExcelStorage().WithFilename(filename)
+			defer storage.Close()
+
+			products, err := storage.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(products, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", products, tt.want)
+			}
+			if report := storage.LoadReport(); len(report.Issues) != 0 {
+				t.Errorf("LoadReport().Issues = %+v, want пустой", report.Issues)
+			}
+
+			// Обновленный файл сохраняется с текущей версией схемы на скрытом листе
+			if err := storage.Save(products); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			file, err := excelize.OpenFile(filename)
+			if err != nil {
+				t.Fatalf("OpenFile() error = %v", err)
+			}
+			defer file.Close()
+			if version, err := readSchemaVersion(file); err != nil || version != SchemaVersion {
+				t.Errorf("readSchemaVersion() = %d, %v, want %d", version, err, SchemaVersion)
+			}
+			if visible, err := file.GetSheetVisible(metaSheet); err != nil || visible {
+				t.Errorf("GetSheetVisible(%s) = %v, %v, want скрытый лист", metaSheet, visible, err)
+			}
+
+			reloaded, err := storage.Load()
+			if err != nil || !reflect.DeepEqual(reloaded, tt.want) {
+				t.Errorf("Load() после Save = %+v, %v, want %+v", reloaded, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcelStorage_LoadNewerSchema(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	file := excelize.NewFile()
+	if _, err := file.NewSheet(metaSheet); err != nil {
+		t.Fatalf("NewSheet() error = %v", err)
+	}
+	row := []interface{}{schemaVersionKey, SchemaVersion + 1}
+	if err := file.SetSheetRow(metaSheet, "A1", &row); err != nil {
+		t.Fatalf("SetSheetRow() error = %v", err)
+	}
+	if err := file.SaveAs(filename); err != nil {
+		t.Fatalf("SaveAs() error = %v", err)
+	}
+	file.Close()
+
+	storage := NewExcelStorage().WithFilename(filename)
+	defer storage.Close()
+	if _, err := storage.Load(); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("Load() error = %v, want %v", err, ErrNewerSchema)
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+)
+
+// LoadIssue проблема в ячейке файла базы данных, обнаруженная при загрузке
+type LoadIssue struct {
+	Sheet  string `json:"sheet"`
+	Row    int    `json:"row"`    // номер строки на листе, начиная с 1
+	Column string `json:"column"` // буква колонки, например "A"
+	Value  string `json:"value"`  // исходное значение ячейки
+	Reason string `json:"reason"`
+}
+
+// QuarantinedRow строка, которую не удалось загрузить как продукт.
+// Она хранится в карантине, пока ее не исправят и не импортируют заново
+// или не удалят, поэтому данные не пропадают при сохранении.
+type QuarantinedRow struct {
+	// Key идентифицирует строку до следующей загрузки
+	Key   string `json:"key"`
+	Sheet string `json:"sheet"`
+	Row   int    `json:"row"`
+	// Cells исходные значения колонок продукта в порядке ID, наименование,
+	// время обработки, расчет времени, версия, время изменения,
+	// а за ними значения дополнительных колонок
+	Cells []string `json:"cells"`
+	// ExtraHeaders заголовки дополнительных колонок в порядке Cells
+	ExtraHeaders []string `json:"extraHeaders"`
+	// Reason почему строка не загружена
+	Reason string `json:"reason"`
+}
+
+// LoadReport отчет о последней загрузке: проблемы в отдельных ячейках
+// и строки, помещенные в карантин
+type LoadReport struct {
+	Issues     []LoadIssue      `json:"issues"`
+	Quarantine []QuarantinedRow `json:"quarantine"`
+}
+
+// ReportingStorage интерфейс хранилища, которое сообщает о проблемах последней
+// загрузки и хранит незагруженные строки в карантине
+type ReportingStorage interface {
+	Storage
+	LoadReport() LoadReport
+	// SetQuarantine заменяет строки карантина, изменение записывается при следующем Save
+	SetQuarantine(rows []QuarantinedRow)
+}
+
+// quarantineKey возвращает ключ строки карантина
+func quarantineKey(sheet string, row int) string {
+	return fmt.Sprintf("%s!%d", sheet, row)
+}
+
+// clone возвращает копию отчета, которую можно изменять
+func (r LoadReport) clone() LoadReport {
+	report := LoadReport{
+		Issues:     slices.Clone(r.Issues),
+		Quarantine: make([]QuarantinedRow, len(r.Quarantine)),
+	}
+	for i, row := range r.Quarantine {
+		row.Cells = slices.Clone(row.Cells)
+		row.ExtraHeaders = slices.Clone(row.ExtraHeaders)
+		report.Quarantine[i] = row
+	}
+	if report.Issues == nil {
+		report.Issues = []LoadIssue{}
+	}
+	return report
+}
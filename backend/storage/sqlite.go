@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+
+	// Драйвер SQLite на чистом Go, не требует cgo
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema схема базы данных SQLite
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS products (
+	id               INTEGER PRIMARY KEY,
+	name             TEXT    NOT NULL,
+	processing_time  REAL    NOT NULL DEFAULT 0,
+	time_calculation TEXT    NOT NULL DEFAULT '',
+	version          INTEGER NOT NULL DEFAULT 0,
+	updated_at       TEXT    NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS constants (
+	name  TEXT PRIMARY KEY,
+	value REAL NOT NULL
+);`
+
+// sqliteAddedColumns колонки, добавленные в таблицу products после первой версии схемы.
+// В существующие базы они добавляются при открытии.
+var sqliteAddedColumns = []struct{ name, definition string }{
+	{"version", "INTEGER NOT NULL DEFAULT 0"},
+	{"updated_at", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// productColumns колонки продукта в порядке сканирования в scanProduct
+const productColumns = `id, name, processing_time, time_calculation, version, updated_at`
+
+// SQLiteStorage реализует интерфейсы IncrementalStorage, ConstantStorage и LockingStorage
+// для работы с базой SQLite. В отличие от ExcelStorage умеет сохранять изменения отдельных продуктов.
+type SQLiteStorage struct {
+	db       *sql.DB
+	filename string
+	lock     dbLock
+}
+
+// Проверка реализации интерфейсов на этапе компиляции
+var (
+	_ IncrementalStorage = (*SQLiteStorage)(nil)
+	_ ConstantStorage    = (*SQLiteStorage)(nil)
+	_ LockingStorage     = (*SQLiteStorage)(nil)
+)
+
+// NewSQLiteStorage создает новый экземпляр хранилища SQLite
+func NewSQLiteStorage() *SQLiteStorage {
+	return &SQLiteStorage{
+		filename: "database.db",
+	}
+}
+
+// WithFilename позволяет указать имя файла
+func (ss *SQLiteStorage) WithFilename(filename string) *SQLiteStorage {
+	ss.filename = filename
+	return ss
+}
+
+// WithLock включает блокировку базы от записи другими копиями приложения:
+// блокировка берется при Load и снимается при Close
+func (ss *SQLiteStorage) WithLock() *SQLiteStorage {
+	ss.lock.enabled = true
+	return ss
+}
+
+// LockError возвращает *LockedError, если базу удерживает другая копия приложения
+func (ss *SQLiteStorage) LockError() error {
+	return ss.lock.writable()
+}
+
+// open открывает базу данных и создает таблицы, если их нет
+func (ss *SQLiteStorage) open() error {
+	if ss.db != nil {
+		return nil
+	}
+
+	// Ждем освобождения базы другим процессом вместо немедленной ошибки
+	db, err := sql.Open("sqlite", ss.filename+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии базы данных: %w", err)
+	}
+	// SQLite не поддерживает параллельную запись, одного соединения достаточно
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return fmt.Errorf("ошибка при создании таблиц: %w", err)
+	}
+	if err := addMissingColumns(db); err != nil {
+		db.Close()
+		return err
+	}
+
+	ss.db = db
+	return nil
+}
+
+// Load загружает продукты из базы данных
+func (ss *SQLiteStorage) Load() (models.Products, error) {
+	var products models.Products
+
+	if err := ss.open(); err != nil {
+		return products, err
+	}
+	if err := ss.lock.acquire(ss.filename); err != nil {
+		return products, err
+	}
+
+	rows, err := ss.db.Query(`SELECT ` + productColumns + ` FROM products ORDER BY id`)
+	if err != nil {
+		return products, fmt.Errorf("ошибка при чтении продуктов: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return products, fmt.Errorf("ошибка при чтении продукта: %w", err)
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return products, fmt.Errorf("ошибка при чтении продуктов: %w", err)
+	}
+
+	return products, nil
+}
+
+// Save полностью заменяет продукты в базе данных в одной транзакции
+func (ss *SQLiteStorage) Save(products models.Products) error {
+	if err := ss.lock.writable(); err != nil {
+		return err
+	}
+	if err := ss.open(); err != nil {
+		return err
+	}
+
+	return ss.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM products`); err != nil {
+			return fmt.Errorf("ошибка при очистке продуктов: %w", err)
+		}
+		for _, product := range products {
+			if err := insertProduct(tx, product); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get возвращает продукт по ID или ErrNotFound
+func (ss *SQLiteStorage) Get(id int) (models.Product, error) {
+	var product models.Product
+
+	if err := ss.open(); err != nil {
+		return product, err
+	}
+
+	product, err := scanProduct(ss.db.QueryRow(`SELECT `+productColumns+` FROM products WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return product, fmt.Errorf("продукт с ID %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return product, fmt.Errorf("ошибка при чтении продукта: %w", err)
+	}
+	return product, nil
+}
+
+// Insert добавляет один продукт
+func (ss *SQLiteStorage) Insert(product models.Product) error {
+	if err := ss.lock.writable(); err != nil {
+		return err
+	}
+	if err := ss.open(); err != nil {
+		return err
+	}
+	return ss.inTx(func(tx *sql.Tx) error {
+		return insertProduct(tx, product)
+	})
+}
+
+// Update обновляет один продукт, если его версия в базе равна version.
+// Базу могут одновременно изменять несколько копий приложения, поэтому
+// продукт, измененный другой копией, не перезаписывается: возвращается ErrConflict.
+// Если продукта нет, возвращается ErrNotFound.
+func (ss *SQLiteStorage) Update(product models.Product, version int) error {
+	if err := ss.lock.writable(); err != nil {
+		return err
+	}
+	if err := ss.open(); err != nil {
+		return err
+	}
+
+	result, err := ss.db.Exec(
+		`UPDATE products SET name = ?, processing_time = ?, time_calculation = ?, version = ?, updated_at = ? WHERE id = ? AND version = ?`,
+		product.Name, product.ProcessingTime, product.TimeCalculation, product.Version, formatUpdatedAt(product.UpdatedAt), product.ID, version,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка при обновлении продукта: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка при обновлении продукта: %w", err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// Продукт удален или изменен другой копией приложения
+	if _, err := ss.Get(product.ID); err != nil {
+		return err
+	}
+	return fmt.Errorf("продукт с ID %d, ожидалась версия %d: %w", product.ID, version, ErrConflict)
+}
+
+// Delete удаляет продукты по ID в одной транзакции
+func (ss *SQLiteStorage) Delete(ids ...int) error {
+	if err := ss.lock.writable(); err != nil {
+		return err
+	}
+	if err := ss.open(); err != nil {
+		return err
+	}
+
+	return ss.inTx(func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := tx.Exec(`DELETE FROM products WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("ошибка при удалении продукта: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// LoadConstants загружает именованные константы в порядке добавления
+func (ss *SQLiteStorage) LoadConstants() (models.Constants, error) {
+	var constants models.Constants
+
+	if err := ss.open(); err != nil {
+		return constants, err
+	}
+
+	rows, err := ss.db.Query(`SELECT name, value FROM constants ORDER BY rowid`)
+	if err != nil {
+		return constants, fmt.Errorf("ошибка при чтении констант: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var constant models.Constant
+		if err := rows.Scan(&constant.Name, &constant.Value); err != nil {
+			return constants, fmt.Errorf("ошибка при чтении константы: %w", err)
+		}
+		constants = append(constants, constant)
+	}
+	if err := rows.Err(); err != nil {
+		return constants, fmt.Errorf("ошибка при чтении констант: %w", err)
+	}
+
+	return constants, nil
+}
+
+// SaveConstants полностью заменяет константы в базе данных
+func (ss *SQLiteStorage) SaveConstants(constants models.Constants) error {
+	if err := ss.lock.writable(); err != nil {
+		return err
+	}
+	if err := ss.open(); err != nil {
+		return err
+	}
+
+	return ss.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM constants`); err != nil {
+			return fmt.Errorf("ошибка при очистке констант: %w", err)
+		}
+		for _, constant := range constants {
+			if _, err := tx.Exec(`INSERT INTO constants (name, value) VALUES (?, ?)`, constant.Name, constant.Value); err != nil {
+				return fmt.Errorf("ошибка при записи константы: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Close закрывает базу данных и снимает блокировку
+func (ss *SQLiteStorage) Close() error {
+	var err error
+	if ss.db != nil {
+		err = ss.db.Close()
+		ss.db = nil
+	}
+	return errors.Join(err, ss.lock.release())
+}
+
+// inTx выполняет функцию в транзакции и откатывает ее при ошибке
+func (ss *SQLiteStorage) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка при начале транзакции: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при сохранении транзакции: %w", err)
+	}
+	return nil
+}
+
+// insertProduct добавляет продукт в рамках транзакции
+func insertProduct(tx *sql.Tx, product models.Product) error {
+	_, err := tx.Exec(
+		`INSERT INTO products (`+productColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		product.ID, product.Name, product.ProcessingTime, product.TimeCalculation, product.Version, formatUpdatedAt(product.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка при записи продукта: %w", err)
+	}
+	return nil
+}
+
+// scanProduct читает продукт из строки результата запроса с колонками productColumns
+func scanProduct(row interface{ Scan(dest ...any) error }) (models.Product, error) {
+	var product models.Product
+	var updatedAt string
+	err := row.Scan(&product.ID, &product.Name, &product.ProcessingTime, &product.TimeCalculation, &product.Version, &updatedAt)
+	product.UpdatedAt = parseUpdatedAt(updatedAt)
+	return product, err
+}
+
+// addMissingColumns добавляет в таблицу products колонки, которых нет в базах,
+// созданных прежними версиями приложения
+func addMissingColumns(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('products')`)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении схемы: %w", err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка при чтении схемы: %w", err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при чтении схемы: %w", err)
+	}
+
+	for _, column := range sqliteAddedColumns {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE products ADD COLUMN ` + column.name + ` ` + column.definition); err != nil {
+			return fmt.Errorf("ошибка при добавлении колонки %s: %w", column.name, err)
+		}
+	}
+	return nil
+}
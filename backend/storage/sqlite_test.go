@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+)
+
+func TestSQLiteStorage_SaveAndLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.db")
+	storage := NewSQLiteStorage().WithFilename(filename)
+
+	// Пустая база создается при первой загрузке
+	products, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(products) != 0 {
+		t.Errorf("Load() из новой базы = %v, хотим пустой слайс", products)
+	}
+
+	testProducts := models.Products{
+		{ID: 1, Name: "Тестовый продукт 1", ProcessingTime: 1.5, TimeCalculation: "1.5"},
+		{ID: 2, Name: "Тестовый продукт 2", ProcessingTime: 2.0, TimeCalculation: "2.0"},
+	}
+	if err := storage.Save(testProducts); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reloaded := NewSQLiteStorage().WithFilename(filename)
+	defer reloaded.Close()
+	loadedProducts, err := reloaded.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loadedProducts, testProducts) {
+		t.Errorf("Load() = %v, want %v", loadedProducts, testProducts)
+	}
+}
+
+func TestSQLiteStorage_IncrementalOperations(t *testing.T) {
+	storage := NewSQLiteStorage().WithFilename(filepath.Join(t.TempDir(), "test.db"))
+	defer storage.Close()
+
+	initial := models.Products{
+		{ID: 1, Name: "Продукт 1", ProcessingTime: 1, TimeCalculation: "1"},
+		{ID: 2, Name: "Продукт 2", ProcessingTime: 2, TimeCalculation: "2"},
+		{ID: 3, Name: "Продукт 3", ProcessingTime: 3, TimeCalculation: "3"},
+	}
+	if err := storage.Save(initial); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if err := storage.Insert(models.Product{ID: 4, Name: "Продукт 4", ProcessingTime: 4, TimeCalculation: "2*2"}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if err := storage.Insert(models.Product{ID: 4, Name: "Дубликат"}); err == nil {
+		t.Errorf("Insert() с существующим ID должен вернуть ошибку")
+	}
+
+	updated := models.Product{ID: 2, Name: "Обновленный", ProcessingTime: 5, TimeCalculation: "5"}
+	if err := storage.Update(updated, 0); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := storage.Update(models.Product{ID: 99}, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update() несуществующего продукта error = %v, want ErrNotFound", err)
+	}
+
+	product, err := storage.Get(2)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if product != updated {
+		t.Errorf("Get() = %v, want %v", product, updated)
+	}
+	if _, err := storage.Get(99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() несуществующего продукта error = %v, want ErrNotFound", err)
+	}
+
+	if err := storage.Delete(1, 3); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	loaded, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	expected := models.Products{
+		updated,
+		{ID: 4, Name: "Продукт 4", ProcessingTime: 4, TimeCalculation: "2*2"},
+	}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Load() = %v, want %v", loaded, expected)
+	}
+}
+
+func TestSQLiteStorage_UpdateConflict(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.db")
+	first := NewSQLiteStorage().WithFilename(filename)
+	defer first.Close()
+	second := NewSQLiteStorage().WithFilename(filename)
+	defer second.Close()
+
+	if err := first.Save(models.Products{{ID: 1, Name: "Вал", ProcessingTime: 1, TimeCalculation: "1", Version: 1}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// Обе копии приложения видели версию 1, первая успевает сохранить изменение
+	saved := models.Product{ID: 1, Name: "Вал первой копии", ProcessingTime: 1, TimeCalculation: "1", Version: 2}
+	if err := first.Update(saved, 1); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	stale := models.Product{ID: 1, Name: "Вал второй копии", ProcessingTime: 1, TimeCalculation: "1", Version: 2}
+	if err := second.Update(stale, 1); !errors.Is(err, ErrConflict) {
+		t.Errorf("Update() устаревшей версии error = %v, want %v", err, ErrConflict)
+	}
+
+	got, err := second.Get(1)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got != saved {
+		t.Errorf("Get() = %v, want %v", got, saved)
+	}
+}
+
+func TestSQLiteStorage_Constants(t *testing.T) {
+	storage := NewSQLiteStorage().WithFilename(filepath.Join(t.TempDir(), "test.db"))
+	defer storage.Close()
+
+	testConstants := models.Constants{
+		{Name: "setup_cnc", Value: 0.75},
+		{Name: "changeover", Value: 0.5},
+	}
+	if err := storage.SaveConstants(testConstants); err != nil {
+		t.Fatalf("SaveConstants() error = %v", err)
+	}
+
+	loaded, err := storage.LoadConstants()
+	if err != nil {
+		t.Fatalf("LoadConstants() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, testConstants) {
+		t.Errorf("LoadConstants() = %v, want %v", loaded, testConstants)
+	}
+}
+
+func TestNewForFile(t *testing.T) {
+	tests := map[string]interface{}{
+		"database.xlsx":   &ExcelStorage{},
+		"database.db":     &SQLiteStorage{},
+		"data/reg.SQLITE": &SQLiteStorage{},
+		"reg.sqlite3":     &SQLiteStorage{},
+		"database":        &ExcelStorage{},
+	}
+
+	for filename, expected := range tests {
+		if got := NewForFile(filename, false, ExcelOptions{}); reflect.TypeOf(got) != reflect.TypeOf(expected) {
+			t.Errorf("NewForFile(%q) = %T, want %T", filename, got, expected)
+		}
+	}
+
+	// Политика резервных копий берется из настроек, пустая заменяется политикой по умолчанию
+	policy := BackupPolicy{KeepLast: 5, KeepDays: 0}
+	if excelStorage := NewForFile("database.xlsx", false, ExcelOptions{Backups: policy}).(*ExcelStorage); *excelStorage.backupPolicy != policy {
+		t.Errorf("NewForFile() политика резервных копий = %+v, want %+v", *excelStorage.backupPolicy, policy)
+	}
+	if excelStorage := NewForFile("database.xlsx", false, ExcelOptions{}).(*ExcelStorage); *excelStorage.backupPolicy != DefaultBackupPolicy {
+		t.Errorf("NewForFile() политика резервных копий = %+v, want %+v", *excelStorage.backupPolicy, DefaultBackupPolicy)
+	}
+
+	// Режим просмотра не блокирует базу, не ведет резервные копии и не создает файл
+	if excelStorage := NewForFile("database.xlsx", true, ExcelOptions{}).(*ExcelStorage); excelStorage.lock.enabled || excelStorage.backupPolicy != nil || !excelStorage.readOnly {
+		t.Errorf("NewForFile() в режиме просмотра включил блокировку или резервные копии или разрешил создание файла")
+	}
+	if sqliteStorage := NewForFile("database.db", true, ExcelOptions{}).(*SQLiteStorage); sqliteStorage.lock.enabled {
+		t.Errorf("NewForFile() в режиме просмотра включил блокировку")
+	}
+}
+
+func TestSQLiteStorage_Versions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.db")
+
+	// База, созданная до появления версий, дополняется новыми колонками
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE products (
+		id INTEGER PRIMARY KEY, name TEXT NOT NULL,
+		processing_time REAL NOT NULL DEFAULT 0, time_calculation TEXT NOT NULL DEFAULT ''
+	); INSERT INTO products VALUES (1, 'Старый продукт', 2, '2')`); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	db.Close()
+
+	storage := NewSQLiteStorage().WithFilename(filename)
+	defer storage.Close()
+
+	products, err := storage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := models.Products{{ID: 1, Name: "Старый продукт", ProcessingTime: 2, TimeCalculation: "2"}}
+	if !reflect.DeepEqual(products, want) {
+		t.Fatalf("Load() = %v, want %v", products, want)
+	}
+
+	updated := models.Product{
+		ID: 1, Name: "Старый продукт", ProcessingTime: 3, TimeCalculation: "3",
+		Version: 4, UpdatedAt: time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC),
+	}
+	if err := storage.Update(updated, 0); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, err := storage.Get(1)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, updated) {
+		t.Errorf("Get() = %v, want %v", got, updated)
+	}
+}
@@ -1,6 +1,20 @@
 package storage
 
-import "github.com/Mr-Cheen1/go-reg-wails/backend/models"
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+)
+
+// ErrNotFound возвращается, если запись не найдена в хранилище
+var ErrNotFound = errors.New("запись не найдена")
+
+// ErrConflict возвращается при сохранении, если файл базы данных
+// изменен другой программой после последней загрузки
+var ErrConflict = errors.New("файл базы данных изменен другой программой")
 
 // Storage интерфейс для хранилища данных
 type Storage interface {
@@ -8,3 +22,103 @@ type Storage interface {
 	Save(products models.Products) error
 	Close() error
 }
+
+// IncrementalStorage интерфейс хранилища, умеющего сохранять изменения отдельных
+// продуктов без перезаписи всего списка. Update и Get возвращают ErrNotFound,
+// если продукта с таким ID нет. Update записывает продукт, только если его версия
+// в хранилище равна version, иначе продукт изменил кто-то другой и возвращается ErrConflict.
+type IncrementalStorage interface {
+	Storage
+	Get(id int) (models.Product, error)
+	Insert(product models.Product) error
+	Update(product models.Product, version int) error
+	Delete(ids ...int) error
+}
+
+// ConstantStorage интерфейс хранилища именованных констант формул.
+// Реализуется хранилищами, которые умеют сохранять константы вместе с продуктами.
+type ConstantStorage interface {
+	LoadConstants() (models.Constants, error)
+	SaveConstants(constants models.Constants) error
+}
+
+// BackupStorage интерфейс хранилища, которое ведет резервные копии файла базы данных
+type BackupStorage interface {
+	ListBackups() ([]Backup, error)
+	RestoreBackup(id string) error
+}
+
+// WatchedStorage интерфейс хранилища, которое замечает изменения файла базы данных
+// другими программами. Changed сообщает, изменился ли файл после последней
+// загрузки или сохранения; после Load изменения считаются принятыми.
+// Save такого хранилища не перезаписывает чужие изменения и возвращает ErrConflict.
+type WatchedStorage interface {
+	Storage
+	Changed() (bool, error)
+}
+
+// LockingStorage интерфейс хранилища, которое блокирует базу данных от записи
+// другими копиями приложения. Блокировка берется при Load и снимается при Close.
+// Если базу уже удерживает другая копия, Load все равно загружает данные,
+// LockError возвращает *LockedError, а сохранение отклоняется с той же ошибкой.
+type LockingStorage interface {
+	Storage
+	LockError() error
+}
+
+// ExcelOptions настройки Excel файлов: лист с продуктами, дополнительные
+// заголовки колонок и политика хранения резервных копий. Пустая политика
+// заменяется DefaultBackupPolicy.
+type ExcelOptions struct {
+	Sheet         string
+	ColumnAliases ColumnAliases
+	Backups       BackupPolicy
+}
+
+// NewForFile создает хранилище по расширению файла:
+// .db, .sqlite и .sqlite3 открываются как SQLite, остальные как Excel
+// с резервным копированием и настройками excel.
+// Хранилище блокирует базу от записи другими копиями приложения, кроме
+// режима просмотра readOnly: он не должен мешать редактировать базу другим
+// и не создает Excel файл, которого нет.
+func NewForFile(filename string, readOnly bool, excel ExcelOptions) Storage {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".db", ".sqlite", ".sqlite3":
+		sqliteStorage := NewSQLiteStorage().WithFilename(filename)
+		if !readOnly {
+			sqliteStorage.WithLock()
+		}
+		return sqliteStorage
+	default:
+		excelStorage := NewExcelStorage().WithFilename(filename).
+			WithSheet(excel.Sheet).
+			WithColumnAliases(excel.ColumnAliases)
+		if readOnly {
+			excelStorage.WithReadOnly()
+		} else {
+			policy := excel.Backups
+			if policy == (BackupPolicy{}) {
+				policy = DefaultBackupPolicy
+			}
+			excelStorage.WithBackups(policy).WithLock()
+		}
+		return excelStorage
+	}
+}
+
+// formatUpdatedAt записывает время изменения продукта в UTC, пустое время остается пустой строкой
+func formatUpdatedAt(updatedAt time.Time) string {
+	if updatedAt.IsZero() {
+		return ""
+	}
+	return updatedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// parseUpdatedAt читает время изменения продукта, некорректное значение считается пустым
+func parseUpdatedAt(value string) time.Time {
+	updatedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return updatedAt.UTC()
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileState снимок состояния файла базы данных, по которому определяется,
+// изменила ли его другая программа
+type fileState struct {
+	known  bool // снимок сделан
+	exists bool
+	size   int64
+	hash   [sha256.Size]byte
+}
+
+// readFileState делает снимок состояния файла. Отсутствующий файл не считается ошибкой.
+func readFileState(filename string) (fileState, error) {
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return fileState{known: true}, nil
+	}
+	if err != nil {
+		return fileState{}, fmt.Errorf("ошибка при открытии файла: %w", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return fileState{}, fmt.Errorf("ошибка при чтении файла: %w", err)
+	}
+
+	state := fileState{known: true, exists: true, size: size}
+	hasher.Sum(state.hash[:0])
+	return state, nil
+}
+
+// sameContent сообщает, совпадает ли содержимое файлов в двух снимках.
+// Пересохранение файла без правок изменением не считается.
+func (fs fileState) sameContent(other fileState) bool {
+	return fs.exists == other.exists && fs.size == other.size && fs.hash == other.hash
+}
+
+// fileWatcher отслеживает изменения файла другими программами
+// относительно последней загрузки или сохранения
+type fileWatcher struct {
+	state fileState
+}
+
+// remember запоминает текущее состояние файла как известное приложению
+func (fw *fileWatcher) remember(filename string) error {
+	state, err := readFileState(filename)
+	if err != nil {
+		return err
+	}
+	fw.state = state
+	return nil
+}
+
+// changed сообщает, изменился ли файл после последнего вызова remember.
+// Пока состояние не запомнено, файл считается неизмененным.
+func (fw *fileWatcher) changed(filename string) (bool, error) {
+	if !fw.state.known {
+		return false, nil
+	}
+
+	// Время изменения ненадежно: его точность зависит от файловой системы,
+	// поэтому файл сравнивается по содержимому
+	state, err := readFileState(filename)
+	if err != nil {
+		return false, err
+	}
+	return !state.sameContent(fw.state), nil
+}
@@ -1,22 +1,42 @@
 package utils
 
 import (
-	"strconv"
+	"math"
 	"strings"
 )
 
-// CalculateTime вычисляет общее время из строки с формулой
+// CalculateTime вычисляет общее время из строки с формулой.
+// Некорректная формула дает 0, для проверки формулы используйте CalculateTimeStrict.
 func CalculateTime(timeStr string) float64 {
-	if strings.TrimSpace(timeStr) == "" {
+	total, err := CalculateTimeStrict(timeStr)
+	if err != nil {
 		return 0
 	}
-	parts := strings.Split(timeStr, "+")
-	var total float64
-	for _, part := range parts {
-		val, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
-		if err == nil {
-			total += val
-		}
-	}
 	return total
 }
+
+// CalculateTimeStrict вычисляет общее время в часах из строки с формулой
+// и возвращает *FormulaError, если формула некорректна.
+// Пустая формула считается допустимой и дает 0.
+func CalculateTimeStrict(timeStr string) (float64, error) {
+	return CalculateTimeWith(timeStr, nil)
+}
+
+// CalculateTimeWith вычисляет время как CalculateTimeStrict,
+// подставляя в формулу значения констант из окружения
+func CalculateTimeWith(timeStr string, env *Env) (float64, error) {
+	if strings.TrimSpace(timeStr) == "" {
+		return 0, nil
+	}
+	total, err := EvaluateWith(timeStr, env)
+	if err != nil {
+		return 0, err
+	}
+	return roundHours(total), nil
+}
+
+// roundHours убирает погрешность двоичной арифметики,
+// возникающую, например, при переводе минут в часы
+func roundHours(hours float64) float64 {
+	return math.Round(hours*1e9) / 1e9
+}
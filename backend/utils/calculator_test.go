@@ -1,6 +1,9 @@
 package utils
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestCalculateTime(t *testing.T) {
 	tests := []struct {
@@ -39,9 +42,29 @@ func TestCalculateTime(t *testing.T) {
 			expected: 4.0,
 		},
 		{
-			name:     "Невалидные части строки игнорируются",
+			name:     "Невалидная формула дает 0",
 			timeStr:  "1.5+abc+2.5",
-			expected: 4.0,
+			expected: 0,
+		},
+		{
+			name:     "Приоритет умножения",
+			timeStr:  "2*3+1",
+			expected: 7,
+		},
+		{
+			name:     "Скобки и деление",
+			timeStr:  "(4-1)/2",
+			expected: 1.5,
+		},
+		{
+			name:     "Унарный минус",
+			timeStr:  "-2+5",
+			expected: 3,
+		},
+		{
+			name:     "Дробные числа через запятую",
+			timeStr:  "1,5+2,25",
+			expected: 3.75,
 		},
 	}
 
@@ -55,3 +78,168 @@ func TestCalculateTime(t *testing.T) {
 		})
 	}
 }
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected float64
+		wantErr  bool
+	}{
+		{name: "Вложенные скобки", expr: "((1+2)*(3-1))/4", expected: 1.5},
+		{name: "Двойной унарный минус", expr: "--3", expected: 3},
+		{name: "Унарный минус перед скобкой", expr: "-(2+3)*2", expected: -10},
+		{name: "Деление слева направо", expr: "8/2/2", expected: 2},
+		{name: "Вычитание слева направо", expr: "10-3-2", expected: 5},
+		{name: "Число без целой части", expr: ".5+,5", expected: 1},
+		{name: "Пустая формула", expr: "  ", wantErr: true},
+		{name: "Незакрытая скобка", expr: "(1+2", wantErr: true},
+		{name: "Лишняя закрывающая скобка", expr: "1+2)", wantErr: true},
+		{name: "Оператор в конце", expr: "1+", wantErr: true},
+		{name: "Два числа подряд", expr: "1 2", wantErr: true},
+		{name: "Некорректное число", expr: "1.2.3", wantErr: true},
+		{name: "Недопустимый символ", expr: "2+abc", wantErr: true},
+		{name: "Деление на ноль", expr: "1/(2-2)", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Evaluate(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if !tt.wantErr && result != tt.expected {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCalculateTimeStrict(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeStr  string
+		expected float64
+		wantErr  *FormulaError
+	}{
+		{name: "Пустая строка допустима", timeStr: " ", expected: 0},
+		{name: "Корректная формула", timeStr: "2+3*2", expected: 8},
+		{
+			name:    "Неизвестная константа",
+			timeStr: "2+abc+3",
+			wantErr: &FormulaError{Position: 3, Token: "abc", Message: "неизвестная константа"},
+		},
+		{
+			name:    "Недопустимый символ",
+			timeStr: "2+$",
+			wantErr: &FormulaError{Position: 3, Token: "$", Message: "недопустимый символ"},
+		},
+		{
+			name:    "Некорректное число",
+			timeStr: "1+2.5.1",
+			wantErr: &FormulaError{Position: 3, Token: "2.5.1", Message: "некорректное число"},
+		},
+		{
+			name:    "Неожиданный конец формулы",
+			timeStr: "2*(3+",
+			wantErr: &FormulaError{Position: 6, Message: "неожиданный конец формулы"},
+		},
+		{
+			name:    "Деление на ноль",
+			timeStr: "4/0",
+			wantErr: &FormulaError{Position: 2, Token: "/", Message: "деление на ноль"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CalculateTimeStrict(tt.timeStr)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("CalculateTimeStrict() error = %v", err)
+				}
+				if result != tt.expected {
+					t.Errorf("CalculateTimeStrict() = %v, want %v", result, tt.expected)
+				}
+				return
+			}
+
+			formulaErr, ok := err.(*FormulaError)
+			if !ok {
+				t.Fatalf("CalculateTimeStrict() error = %v, want *FormulaError", err)
+			}
+			if *formulaErr != *tt.wantErr {
+				t.Errorf("CalculateTimeStrict() error = %+v, want %+v", *formulaErr, *tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCalculateTimeWith(t *testing.T) {
+	env := &Env{Variables: map[string]float64{"setup_cnc": 0.75, "наладка": 0.5}}
+
+	tests := []struct {
+		name     string
+		timeStr  string
+		expected float64
+	}{
+		{name: "Константа с выражением", timeStr: "setup_cnc + 3*2.1", expected: 7.05},
+		{name: "Кириллическое имя", timeStr: "наладка*2", expected: 1},
+		{name: "Константа и единицы", timeStr: "setup_cnc + 15мин", expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CalculateTimeWith(tt.timeStr, env)
+			if err != nil {
+				t.Fatalf("CalculateTimeWith(%q) error = %v", tt.timeStr, err)
+			}
+			if result != tt.expected {
+				t.Errorf("CalculateTimeWith(%q) = %v, want %v", tt.timeStr, result, tt.expected)
+			}
+		})
+	}
+
+	_, err := CalculateTimeWith("setup_cnc + setup_lathe", env)
+	formulaErr, ok := err.(*FormulaError)
+	if !ok || formulaErr.Position != 13 || formulaErr.Token != "setup_lathe" {
+		t.Errorf("CalculateTimeWith() с неизвестной константой error = %v", err)
+	}
+}
+
+func TestFormulaVariables(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{expr: "setup_cnc + 3*2.1", expected: []string{"setup_cnc"}},
+		{expr: "a + b*(a - c) + 30мин", expected: []string{"a", "b", "c"}},
+		{expr: "1+2", expected: nil},
+		{expr: "a +", expected: nil},
+	}
+
+	for _, tt := range tests {
+		if result := FormulaVariables(tt.expr); !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("FormulaVariables(%q) = %v, want %v", tt.expr, result, tt.expected)
+		}
+	}
+}
+
+func TestIsValidName(t *testing.T) {
+	tests := map[string]bool{
+		"setup_cnc": true,
+		"_tmp1":     true,
+		"наладка":   true,
+		"":          false,
+		"1setup":    false,
+		"setup-cnc": false,
+		"ч":         false,
+		"MIN":       false,
+	}
+
+	for name, expected := range tests {
+		if result := IsValidName(name); result != expected {
+			t.Errorf("IsValidName(%q) = %v, want %v", name, result, expected)
+		}
+	}
+}
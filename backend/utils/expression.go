@@ -0,0 +1,468 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FormulaError описывает ошибку в формуле расчета времени
+type FormulaError struct {
+	Position int    `json:"position"` // позиция ошибки в символах, начиная с 1
+	Token    string `json:"token"`    // лексема, на которой произошла ошибка
+	Message  string `json:"message"`
+	// Err ошибка продукта, на который ссылается формула
+	Err error `json:"-"`
+}
+
+// Error реализует интерфейс error
+func (e *FormulaError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("позиция %d: %s", e.Position, e.Message)
+	}
+	return fmt.Sprintf("позиция %d: %s %q", e.Position, e.Message, e.Token)
+}
+
+// Unwrap возвращает ошибку продукта, на который ссылается формула,
+// чтобы errors.Is находил ErrCyclicReference
+func (e *FormulaError) Unwrap() error {
+	return e.Err
+}
+
+// tokenKind тип лексемы формулы
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenRef
+	tokenPlus
+	tokenMinus
+	tokenStar
+	tokenSlash
+	tokenLParen
+	tokenRParen
+)
+
+// token лексема формулы
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value float64
+}
+
+// tokenize разбивает формулу на лексемы.
+// Позиции считаются в символах, а не в байтах, чтобы корректно работать с кириллицей.
+func tokenize(expr string) ([]token, error) {
+	runes := []rune(expr)
+	var tokens []token
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.' || r == ',':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == ',') {
+				i++
+			}
+			text := string(runes[start:i])
+			// Операторы вводят дробные числа через запятую: "1,5"
+			value, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", "."), 64)
+			if err != nil {
+				return nil, &FormulaError{Position: start + 1, Token: text, Message: "некорректное число"}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, pos: start, value: value})
+		case isIdentStart(r):
+			start := i
+			for i < len(runes) && isIdentPart(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+		case r == '#':
+			// Ссылка на другой продукт: "#12"
+			start := i
+			i++
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			text := string(runes[start:i])
+			id, err := strconv.Atoi(text[1:])
+			if err != nil || id <= 0 {
+				return nil, &FormulaError{Position: start + 1, Token: text, Message: "ожидался ID продукта после #"}
+			}
+			tokens = append(tokens, token{kind: tokenRef, text: text, pos: start, value: float64(id)})
+		default:
+			kind, ok := operatorTokens[r]
+			if !ok {
+				return nil, &FormulaError{Position: i + 1, Token: string(r), Message: "недопустимый символ"}
+			}
+			tokens = append(tokens, token{kind: kind, text: string(r), pos: i})
+			i++
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// isIdentStart проверяет, может ли символ начинать имя
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+// isIdentPart проверяет, может ли символ входить в имя
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
+
+// operatorTokens соответствие символов операторов типам лексем
+var operatorTokens = map[rune]tokenKind{
+	'+': tokenPlus,
+	'-': tokenMinus,
+	'*': tokenStar,
+	'/': tokenSlash,
+	'(': tokenLParen,
+	')': tokenRParen,
+}
+
+// Env окружение вычисления формулы: значения именованных констант,
+// функция получения времени обработки продукта по ссылке "#ID"
+// и длительность смены в часах (0 означает DefaultShiftHours)
+type Env struct {
+	Variables  map[string]float64
+	Product    func(id int) (float64, error)
+	ShiftHours float64
+}
+
+// unitHours возвращает длительность единицы измерения в часах
+func (env *Env) unitHours(unit Unit) float64 {
+	if unit.Name == unitShift && env != nil && env.ShiftHours > 0 {
+		return env.ShiftHours
+	}
+	return unit.Hours
+}
+
+// node узел дерева разбора формулы
+type node interface {
+	eval(env *Env) (float64, error)
+}
+
+// numberNode числовая константа
+type numberNode struct {
+	value float64
+}
+
+func (n numberNode) eval(*Env) (float64, error) {
+	return n.value, nil
+}
+
+// unitNode число с единицей измерения: длительность смены известна
+// только при вычислении
+type unitNode struct {
+	value float64
+	unit  Unit
+}
+
+func (n unitNode) eval(env *Env) (float64, error) {
+	return n.value * env.unitHours(n.unit), nil
+}
+
+// variableNode ссылка на именованную константу
+type variableNode struct {
+	name string
+	pos  int
+}
+
+func (n variableNode) eval(env *Env) (float64, error) {
+	if env != nil {
+		if value, ok := env.Variables[n.name]; ok {
+			return value, nil
+		}
+	}
+	return 0, &FormulaError{Position: n.pos + 1, Token: n.name, Message: "неизвестная константа"}
+}
+
+// refNode ссылка на время обработки другого продукта
+type refNode struct {
+	id  int
+	pos int
+}
+
+func (n refNode) eval(env *Env) (float64, error) {
+	if env == nil || env.Product == nil {
+		return 0, &FormulaError{Position: n.pos + 1, Token: fmt.Sprintf("#%d", n.id), Message: "ссылки на продукты здесь недоступны"}
+	}
+	value, err := env.Product(n.id)
+	if err != nil {
+		return 0, &FormulaError{Position: n.pos + 1, Token: fmt.Sprintf("#%d", n.id), Message: err.Error(), Err: err}
+	}
+	return value, nil
+}
+
+// unaryNode унарный минус
+type unaryNode struct {
+	operand node
+}
+
+func (n unaryNode) eval(env *Env) (float64, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	return -value, nil
+}
+
+// binaryNode бинарная операция
+type binaryNode struct {
+	op          tokenKind
+	left, right node
+	pos         int
+}
+
+func (n binaryNode) eval(env *Env) (float64, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case tokenPlus:
+		return left + right, nil
+	case tokenMinus:
+		return left - right, nil
+	case tokenStar:
+		return left * right, nil
+	case tokenSlash:
+		if right == 0 {
+			return 0, &FormulaError{Position: n.pos + 1, Token: "/", Message: "деление на ноль"}
+		}
+		return left / right, nil
+	}
+	return 0, &FormulaError{Position: n.pos + 1, Message: "неизвестная операция"}
+}
+
+// parser разбирает формулу методом рекурсивного спуска.
+//
+// Грамматика:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = ("-" | "+") unary | primary
+//	primary = number [ unit ] | name | "#" id | "(" expr ")"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseExpr() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenPlus || p.peek().kind == tokenMinus {
+		op := p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op.kind, left: left, right: right, pos: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenStar || p.peek().kind == tokenSlash {
+		op := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op.kind, left: left, right: right, pos: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch p.peek().kind {
+	case tokenMinus:
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{operand: operand}, nil
+	case tokenPlus:
+		p.next()
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		if p.peek().kind != tokenIdent {
+			return numberNode{value: tok.value}, nil
+		}
+		// Число с единицей измерения: "30мин", "1.5h", "2 смены"
+		unitTok := p.next()
+		unit, ok := lookupUnit(unitTok.text)
+		if !ok {
+			return nil, &FormulaError{Position: unitTok.pos + 1, Token: unitTok.text, Message: "неизвестная единица измерения"}
+		}
+		return unitNode{value: tok.value, unit: unit}, nil
+	case tokenLParen:
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, unexpectedToken(closing, "ожидалась закрывающая скобка, найдено")
+		}
+		return inner, nil
+	case tokenIdent:
+		return variableNode{name: tok.text, pos: tok.pos}, nil
+	case tokenRef:
+		return refNode{id: int(tok.value), pos: tok.pos}, nil
+	}
+	return nil, unexpectedToken(tok, "ожидалось число или открывающая скобка, найдено")
+}
+
+// unexpectedToken формирует ошибку о неожиданной лексеме
+func unexpectedToken(tok token, message string) *FormulaError {
+	if tok.kind == tokenEOF {
+		return &FormulaError{Position: tok.pos + 1, Message: "неожиданный конец формулы"}
+	}
+	return &FormulaError{Position: tok.pos + 1, Token: tok.text, Message: message}
+}
+
+// parse строит дерево разбора формулы
+func parse(expr string) (node, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, &FormulaError{Position: 1, Message: "пустая формула"}
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, unexpectedToken(tok, "ожидался оператор, найдено")
+	}
+	return root, nil
+}
+
+// Evaluate вычисляет арифметическое выражение.
+// Поддерживаются операции +, -, *, /, скобки, унарный минус
+// дробные числа как с точкой, так и с запятой ("1,5"),
+// а также единицы измерения времени (см. Units). Результат в часах.
+// Ошибки разбора и вычисления возвращаются как *FormulaError.
+func Evaluate(expr string) (float64, error) {
+	return EvaluateWith(expr, nil)
+}
+
+// EvaluateWith вычисляет выражение, подставляя значения констант из окружения
+func EvaluateWith(expr string, env *Env) (float64, error) {
+	root, err := parse(expr)
+	if err != nil {
+		return 0, err
+	}
+	return root.eval(env)
+}
+
+// walk обходит дерево разбора формулы
+func walk(n node, visit func(node)) {
+	visit(n)
+	switch n := n.(type) {
+	case unaryNode:
+		walk(n.operand, visit)
+	case binaryNode:
+		walk(n.left, visit)
+		walk(n.right, visit)
+	}
+}
+
+// FormulaVariables возвращает имена констант, используемых в формуле, без повторов.
+// Для некорректной формулы возвращается nil.
+func FormulaVariables(expr string) []string {
+	root, err := parse(expr)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	walk(root, func(n node) {
+		if variable, ok := n.(variableNode); ok && !seen[variable.name] {
+			seen[variable.name] = true
+			names = append(names, variable.name)
+		}
+	})
+	return names
+}
+
+// FormulaReferences возвращает ID продуктов, на которые ссылается формула, без повторов.
+// Для некорректной формулы возвращается nil.
+func FormulaReferences(expr string) []int {
+	root, err := parse(expr)
+	if err != nil {
+		return nil
+	}
+
+	var ids []int
+	seen := make(map[int]bool)
+	walk(root, func(n node) {
+		if ref, ok := n.(refNode); ok && !seen[ref.id] {
+			seen[ref.id] = true
+			ids = append(ids, ref.id)
+		}
+	})
+	return ids
+}
+
+// IsValidName проверяет, можно ли использовать имя для константы в формулах.
+// Имя должно начинаться с буквы или "_" и не совпадать с единицей измерения.
+func IsValidName(name string) bool {
+	runes := []rune(name)
+	if len(runes) == 0 || !isIdentStart(runes[0]) {
+		return false
+	}
+	for _, r := range runes[1:] {
+		if !isIdentPart(r) {
+			return false
+		}
+	}
+	_, isUnit := lookupUnit(name)
+	return !isUnit
+}
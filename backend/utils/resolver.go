@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+)
+
+// ErrCyclicReference возвращается, если формулы продуктов ссылаются друг на друга по кругу
+var ErrCyclicReference = errors.New("циклическая ссылка")
+
+// Resolver вычисляет формулы продуктов с учетом констант и ссылок на другие продукты ("#12").
+// Время каждого продукта вычисляется один раз и кешируется.
+type Resolver struct {
+	products   map[int]models.Product
+	variables  map[string]float64
+	shiftHours float64
+	cache      map[int]float64
+	visiting   map[int]bool
+}
+
+// NewResolver создает вычислитель формул для набора продуктов и констант
+func NewResolver(products models.Products, constants models.Constants) *Resolver {
+	byID := make(map[int]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	return &Resolver{
+		products:  byID,
+		variables: constants.Values(),
+		cache:     make(map[int]float64),
+		visiting:  make(map[int]bool),
+	}
+}
+
+// WithShiftHours задает длительность смены для единицы "смена" в формулах.
+// Без нее используется DefaultShiftHours.
+func (r *Resolver) WithShiftHours(hours float64) *Resolver {
+	r.shiftHours = hours
+	return r
+}
+
+// Calculate вычисляет формулу, не принадлежащую ни одному из продуктов
+func (r *Resolver) Calculate(formula string) (float64, error) {
+	return CalculateTimeWith(formula, r.env())
+}
+
+// ProductTime вычисляет время обработки продукта по его формуле.
+// Продукт без формулы сохраняет указанное в нем время.
+func (r *Resolver) ProductTime(id int) (float64, error) {
+	if value, ok := r.cache[id]; ok {
+		return value, nil
+	}
+
+	product, ok := r.products[id]
+	if !ok {
+		return 0, fmt.Errorf("продукт с ID %d не найден", id)
+	}
+	if r.visiting[id] {
+		return 0, ErrCyclicReference
+	}
+
+	if strings.TrimSpace(product.TimeCalculation) == "" {
+		r.cache[id] = product.ProcessingTime
+		return product.ProcessingTime, nil
+	}
+
+	r.visiting[id] = true
+	value, err := CalculateTimeWith(product.TimeCalculation, r.env())
+	delete(r.visiting, id)
+	if err != nil {
+		return 0, err
+	}
+
+	r.cache[id] = value
+	return value, nil
+}
+
+// env возвращает окружение, разрешающее ссылки на продукты через ProductTime
+func (r *Resolver) env() *Env {
+	return &Env{Variables: r.variables, Product: r.productRef, ShiftHours: r.shiftHours}
+}
+
+// productRef вычисляет время продукта, на который ссылается формула
+func (r *Resolver) productRef(id int) (float64, error) {
+	value, err := r.ProductTime(id)
+	if err == nil || errors.Is(err, ErrCyclicReference) {
+		return value, err
+	}
+
+	var formulaErr *FormulaError
+	if errors.As(err, &formulaErr) {
+		return 0, fmt.Errorf("ошибка в формуле продукта #%d: %s", id, formulaErr.Message)
+	}
+	return 0, err
+}
+
+// Dependents возвращает ID продуктов, формулы которых прямо или через
+// другие продукты ссылаются на любой из указанных ID
+func Dependents(products models.Products, ids ...int) []int {
+	// Формулы разбираются один раз: referrers продукты, ссылающиеся на ID
+	referrers := make(map[int][]int)
+	for _, product := range products {
+		for _, ref := range FormulaReferences(product.TimeCalculation) {
+			referrers[ref] = append(referrers[ref], product.ID)
+		}
+	}
+
+	var result []int
+	seen := make(map[int]bool)
+	queue := slices.Clone(ids)
+
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+
+		for _, id := range referrers[target] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			result = append(result, id)
+			queue = append(queue, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+)
+
+func TestResolverProductTime(t *testing.T) {
+	products := models.Products{
+		{ID: 12, Name: "Вал", TimeCalculation: "1.5 + setup"},
+		{ID: 15, Name: "Втулка", TimeCalculation: "30мин"},
+		{ID: 20, Name: "Сборка", TimeCalculation: "#12 + #15*2 + 0.5"},
+		{ID: 21, Name: "Узел", TimeCalculation: "#20 + #22"},
+		{ID: 22, Name: "Покупное", ProcessingTime: 0.25},
+	}
+	constants := models.Constants{{Name: "setup", Value: 0.5}}
+	resolver := NewResolver(products, constants)
+
+	tests := []struct {
+		id       int
+		expected float64
+	}{
+		{id: 12, expected: 2},
+		{id: 20, expected: 3.5},
+		{id: 21, expected: 3.75},
+		{id: 22, expected: 0.25},
+	}
+	for _, tt := range tests {
+		result, err := resolver.ProductTime(tt.id)
+		if err != nil {
+			t.Fatalf("ProductTime(%d) error = %v", tt.id, err)
+		}
+		if result != tt.expected {
+			t.Errorf("ProductTime(%d) = %v, want %v", tt.id, result, tt.expected)
+		}
+	}
+
+	if result, err := resolver.Calculate("#21 - #22"); err != nil || result != 3.5 {
+		t.Errorf("Calculate(\"#21 - #22\") = %v, %v, want 3.5", result, err)
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	products := models.Products{
+		{ID: 1, TimeCalculation: "#2 + 1"},
+		{ID: 2, TimeCalculation: "#3"},
+		{ID: 3, TimeCalculation: "#1"},
+		{ID: 4, TimeCalculation: "#4"},
+		{ID: 5, TimeCalculation: "#99"},
+		{ID: 6, TimeCalculation: "#7 + 1"},
+		{ID: 7, TimeCalculation: "unknown"},
+	}
+	resolver := NewResolver(products, nil)
+
+	tests := []struct {
+		id      int
+		message string
+		cyclic  bool
+	}{
+		{id: 1, message: "циклическая ссылка", cyclic: true},
+		{id: 4, message: "циклическая ссылка", cyclic: true},
+		{id: 5, message: "продукт с ID 99 не найден"},
+		{id: 6, message: "ошибка в формуле продукта #7: неизвестная константа"},
+	}
+	for _, tt := range tests {
+		_, err := resolver.ProductTime(tt.id)
+		var formulaErr *FormulaError
+		if !errors.As(err, &formulaErr) {
+			t.Fatalf("ProductTime(%d) error = %v, want *FormulaError", tt.id, err)
+		}
+		if !strings.Contains(formulaErr.Message, tt.message) {
+			t.Errorf("ProductTime(%d) error = %q, want содержащую %q", tt.id, formulaErr.Message, tt.message)
+		}
+		if errors.Is(err, ErrCyclicReference) != tt.cyclic {
+			t.Errorf("errors.Is(ProductTime(%d), ErrCyclicReference) = %v, want %v", tt.id, !tt.cyclic, tt.cyclic)
+		}
+	}
+}
+
+func TestFormulaReferences(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected []int
+	}{
+		{expr: "#12 + #15*2 + 0.5", expected: []int{12, 15}},
+		{expr: "#3 + #3", expected: []int{3}},
+		{expr: "1 + setup", expected: nil},
+		{expr: "#1 +", expected: nil},
+	}
+	for _, tt := range tests {
+		if result := FormulaReferences(tt.expr); !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("FormulaReferences(%q) = %v, want %v", tt.expr, result, tt.expected)
+		}
+	}
+
+	for _, expr := range []string{"#", "#0 + 1", "# 5"} {
+		if _, err := CalculateTimeStrict(expr); err == nil {
+			t.Errorf("CalculateTimeStrict(%q) должен вернуть ошибку", expr)
+		}
+	}
+}
+
+func TestDependents(t *testing.T) {
+	products := models.Products{
+		{ID: 1, TimeCalculation: "1"},
+		{ID: 2, TimeCalculation: "#1 * 2"},
+		{ID: 3, TimeCalculation: "#2 + 1"},
+		{ID: 4, TimeCalculation: "#3 + #1"},
+		{ID: 5, TimeCalculation: "5"},
+	}
+
+	tests := []struct {
+		ids      []int
+		expected []int
+	}{
+		{ids: []int{1}, expected: []int{2, 4, 3}},
+		{ids: []int{3}, expected: []int{4}},
+		{ids: []int{5}, expected: nil},
+	}
+	for _, tt := range tests {
+		if result := Dependents(products, tt.ids...); !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Dependents(%v) = %v, want %v", tt.ids, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultShiftHours длительность смены по умолчанию в часах
+const DefaultShiftHours = 8.0
+
+// Unit единица измерения времени в формулах
+type Unit struct {
+	Name    string   // каноническое имя единицы
+	Aliases []string // допустимые обозначения в формулах
+	Hours   float64  // длительность одной единицы в часах
+}
+
+// Units возвращает список поддерживаемых единиц измерения времени.
+// Время в формулах без единицы измерения считается в часах. Длительность
+// смены указана по умолчанию, при вычислении она берется из Env.ShiftHours.
+func Units() []Unit {
+	return []Unit{
+		{Name: "min", Aliases: []string{"m", "min", "м", "мин", "минут", "минуты"}, Hours: 1.0 / 60},
+		{Name: "h", Aliases: []string{"h", "hr", "ч", "час", "часа", "часов"}, Hours: 1},
+		{Name: unitShift, Aliases: []string{"shift", "см", "смена", "смены", "смен"}, Hours: DefaultShiftHours},
+	}
+}
+
+// unitShift имя единицы "смена", длительность которой задается окружением
+const unitShift = "shift"
+
+// ValidateShiftHours проверяет длительность смены в часах
+func ValidateShiftHours(hours float64) error {
+	if hours <= 0 || hours > 24 {
+		return fmt.Errorf("длительность смены должна быть от 0 до 24 часов, получено %v", hours)
+	}
+	return nil
+}
+
+// lookupUnit ищет единицу измерения по обозначению без учета регистра
+func lookupUnit(alias string) (Unit, bool) {
+	alias = strings.ToLower(alias)
+	for _, unit := range Units() {
+		for _, a := range unit.Aliases {
+			if a == alias {
+				return unit, true
+			}
+		}
+	}
+	return Unit{}, false
+}
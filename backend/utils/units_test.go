@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+)
+
+func TestCalculateTimeWithUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeStr  string
+		expected float64
+	}{
+		{name: "Минуты латиницей", timeStr: "30m", expected: 0.5},
+		{name: "Часы латиницей", timeStr: "1.5h", expected: 1.5},
+		{name: "Часы кириллицей", timeStr: "2ч", expected: 2},
+		{name: "Минуты кириллицей", timeStr: "45мин", expected: 0.75},
+		{name: "Смешанные единицы", timeStr: "30m + 1.5h + 2ч + 45мин", expected: 4.75},
+		{name: "Число без единицы в часах", timeStr: "2 + 90 мин", expected: 3.5},
+		{name: "Смена", timeStr: "1 смена + 1,5 ч", expected: 9.5},
+		{name: "Регистр не важен", timeStr: "20 MIN + 1 H", expected: 4.0 / 3},
+		{name: "Единицы в выражении", timeStr: "3*20мин + (10m+5m)*4", expected: 2},
+		{name: "Погрешность округляется", timeStr: "10m+10m+10m", expected: 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CalculateTimeStrict(tt.timeStr)
+			if err != nil {
+				t.Fatalf("CalculateTimeStrict(%q) error = %v", tt.timeStr, err)
+			}
+			if result != roundHours(tt.expected) {
+				t.Errorf("CalculateTimeStrict(%q) = %v, want %v", tt.timeStr, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCalculateTimeUnknownUnit(t *testing.T) {
+	_, err := CalculateTimeStrict("2 дня")
+	formulaErr, ok := err.(*FormulaError)
+	if !ok {
+		t.Fatalf("CalculateTimeStrict() error = %v, want *FormulaError", err)
+	}
+	if formulaErr.Position != 3 || formulaErr.Token != "дня" {
+		t.Errorf("CalculateTimeStrict() error = %+v, want позиция 3, лексема \"дня\"", *formulaErr)
+	}
+}
+
+func TestShiftHours(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      *Env
+		expected float64
+	}{
+		{name: "Без окружения", env: nil, expected: 2 * DefaultShiftHours},
+		{name: "Длительность не задана", env: &Env{}, expected: 2 * DefaultShiftHours},
+		{name: "Смена 12 часов", env: &Env{ShiftHours: 12}, expected: 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CalculateTimeWith("2 смены", tt.env)
+			if err != nil {
+				t.Fatalf("CalculateTimeWith() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("CalculateTimeWith(\"2 смены\") = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+
+	// Вычислитель формул передает длительность смены в ссылки на продукты
+	resolver := NewResolver(models.Products{
+		{ID: 1, TimeCalculation: "0,5 смены"},
+		{ID: 2, TimeCalculation: "#1 * 2"},
+	}, nil).WithShiftHours(10)
+	if result, err := resolver.ProductTime(2); err != nil || result != 10 {
+		t.Errorf("ProductTime(2) = %v, %v, want 10", result, err)
+	}
+
+	for _, hours := range []float64{0, -1, 25} {
+		if err := ValidateShiftHours(hours); err == nil {
+			t.Errorf("ValidateShiftHours(%v) должен вернуть ошибку", hours)
+		}
+	}
+	if err := ValidateShiftHours(12); err != nil {
+		t.Errorf("ValidateShiftHours(12) error = %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/config"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/storage"
+)
+
+// cliUsage справка по командам командной строки
+const cliUsage = `Использование: go-reg-wails cli [-db файл] <команда> [параметры]
+
+Команды:
+  list [-json]                                      все записи
+  search [-json] <запрос>                           поиск по наименованию
+  add <наименование> <формула>                      добавить запись и вывести ее ID
+  update [-name имя] [-time формула] [-version N] <ID>  изменить запись
+  delete <ID>...                                    удалить записи
+  export [-format csv|json] [-o файл]               выгрузить все записи
+
+Без -db используется последняя открытая в приложении база.
+`
+
+// errUsage ошибка в аргументах команды, после нее выводится справка
+var errUsage = errors.New("неверные аргументы")
+
+// cliCommand команда командной строки
+type cliCommand struct {
+	// readOnly команда только читает базу: она не блокирует базу
+	// и не создает ее, если файла нет
+	readOnly bool
+	run      func(app *App, args []string, stdout io.Writer) error
+}
+
+// cliCommands команды командной строки по именам
+var cliCommands = map[string]cliCommand{
+	"list":   {readOnly: true, run: cliList},
+	"search": {readOnly: true, run: cliSearch},
+	"add":    {run: cliAdd},
+	"update": {run: cliUpdate},
+	"delete": {run: cliDelete},
+	"export": {readOnly: true, run: cliExport},
+}
+
+// runCLI выполняет команду командной строки с настройками приложения settings
+// и возвращает код завершения: 0 при успехе, 1 при ошибке выполнения,
+// 2 при неверных аргументах
+func runCLI(args []string, settings config.Settings, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, cliUsage) }
+	dbPath := flags.String("db", "", "файл базы данных")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprint(stderr, cliUsage)
+		return 2
+	}
+	command, ok := cliCommands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "Неизвестная команда %q\n\n%s", flags.Arg(0), cliUsage)
+		return 2
+	}
+
+	// Без флага используется та же база, что открыта в приложении,
+	// а Excel файлы читаются с теми же настройками листа и колонок
+	filename := resolveDatabasePath(*dbPath, settings)
+
+	app, err := openCLIApp(filename, command.readOnly, settings)
+	if err != nil {
+		fmt.Fprintf(stderr, "Ошибка: %v\n", err)
+		return 1
+	}
+	defer app.storage.Close()
+
+	if err := command.run(app, flags.Args()[1:], stdout); err != nil {
+		fmt.Fprintf(stderr, "Ошибка: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(stderr, "\n"+cliUsage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// openCLIApp открывает базу данных для команды командной строки.
+// Изменения проходят через те же проверки, что и в окне приложения,
+// а формулы рассчитываются с той же длительностью смены.
+// Настройки командам не нужно сохранять.
+func openCLIApp(filename string, readOnly bool, settings config.Settings) (*App, error) {
+	if _, err := os.Stat(filename); readOnly && err != nil {
+		return nil, fmt.Errorf("база данных %s не найдена: %w", filename, err)
+	}
+
+	dataStorage := storage.NewForFile(filename, readOnly, excelOptions(settings))
+	app := NewApp(dataStorage).WithReadOnly(readOnly).WithSettings("", settings)
+	// Без окна отправлять события некому
+	app.emit = func(context.Context, string, ...interface{}) {}
+	if err := app.products.Write(app.reload); err != nil {
+		app.storage.Close()
+		return nil, err
+	}
+	return app, nil
+}
+
+// cliList выводит все записи
+func cliList(app *App, args []string, stdout io.Writer) error {
+	flags := newCommandFlags("list")
+	asJSON := flags.Bool("json", false, "вывод в формате JSON")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+	return writeProducts(stdout, app.GetProducts(), *asJSON)
+}
+
+// cliSearch выводит записи, наименование которых содержит запрос
+func cliSearch(app *App, args []string, stdout io.Writer) error {
+	flags := newCommandFlags("search")
+	asJSON := flags.Bool("json", false, "вывод в формате JSON")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+	return writeProducts(stdout, app.SearchProducts(strings.Join(flags.Args(), " ")), *asJSON)
+}
+
+// cliAdd добавляет запись и выводит ее ID
+func cliAdd(app *App, args []string, stdout io.Writer) error {
+	if len(args) != 2 || strings.TrimSpace(args[0]) == "" {
+		return errUsage
+	}
+	product, err := app.addProduct(strings.TrimSpace(args[0]), args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, product.ID)
+	return nil
+}
+
+// cliUpdate изменяет наименование или формулу записи. Без -version запись
+// изменяется независимо от того, кто изменял ее последним.
+func cliUpdate(app *App, args []string, stdout io.Writer) error {
+	flags := newCommandFlags("update")
+	name := flags.String("name", "", "новое наименование")
+	timeCalculation := flags.String("time", "", "новая формула расчета времени")
+	version := flags.Int("version", 0, "ожидаемая версия записи")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+	ids, err := parseIDs(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	product, err := findProduct(app, ids[0])
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		*name = product.Name
+	}
+	if *timeCalculation == "" {
+		*timeCalculation = product.TimeCalculation
+	}
+	if *version == 0 {
+		*version = product.Version
+	}
+	return app.UpdateProduct(product.ID, *version, *name, *timeCalculation)
+}
+
+// cliDelete удаляет записи
+func cliDelete(app *App, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	versions := make(map[int]int, len(ids))
+	for _, id := range ids {
+		product, err := findProduct(app, id)
+		if err != nil {
+			return err
+		}
+		versions[id] = product.Version
+	}
+	return app.DeleteProducts(versions)
+}
+
+// cliExport выгружает все записи в CSV или JSON
+func cliExport(app *App, args []string, stdout io.Writer) (err error) {
+	flags := newCommandFlags("export")
+	format := flags.String("format", "csv", "формат: csv или json")
+	output := flags.String("o", "", "файл для выгрузки, по умолчанию стандартный вывод")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("%w: неизвестный формат %q", errUsage, *format)
+	}
+
+	w := stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("ошибка при создании файла выгрузки: %w", err)
+		}
+		defer func() {
+			if closeErr := file.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("ошибка при записи файла выгрузки: %w", closeErr)
+			}
+		}()
+		w = file
+	}
+
+	if *format == "json" {
+		return writeProducts(w, app.GetProducts(), true)
+	}
+	return writeCSV(w, app.GetProducts())
+}
+
+// newCommandFlags создает набор флагов команды, который не завершает программу при ошибке
+func newCommandFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+// parseIDs разбирает ID записей из аргументов
+func parseIDs(args []string) ([]int, error) {
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: некорректный ID %q", errUsage, arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// findProduct возвращает запись по ID
+func findProduct(app *App, id int) (models.Product, error) {
+	products := models.Products(app.GetProducts())
+	index := products.IndexOf(id)
+	if index < 0 {
+		return models.Product{}, fmt.Errorf("запись #%d не найдена", id)
+	}
+	return products[index], nil
+}
+
+// writeProducts выводит записи таблицей или в формате JSON
+func writeProducts(w io.Writer, products []models.Product, asJSON bool) error {
+	if asJSON {
+		if products == nil {
+			products = []models.Product{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(products)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tНаименование\tВремя, ч\tРасчет времени")
+	for _, product := range products {
+		fmt.Fprintf(table, "%d\t%s\t%.2f\t%s\n", product.ID, product.Name, product.ProcessingTime, product.TimeCalculation)
+	}
+	return table.Flush()
+}
+
+// writeCSV выгружает записи в CSV с заголовком
+func writeCSV(w io.Writer, products []models.Product) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"ID", "Наименование", "Время обработки в часах", "Расчет времени"}); err != nil {
+		return fmt.Errorf("ошибка при записи CSV: %w", err)
+	}
+	for _, product := range products {
+		record := []string{
+			strconv.Itoa(product.ID),
+			product.Name,
+			strconv.FormatFloat(product.ProcessingTime, 'f', -1, 64),
+			product.TimeCalculation,
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("ошибка при записи CSV: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("ошибка при записи CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/config"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/storage"
+)
+
+func TestRunCLI(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "database.db")
+	exported := filepath.Join(dir, "export.csv")
+
+	// Шаги выполняются по порядку над одной базой
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOutput string // ожидаемый вывод или его часть, если partial
+		partial    bool
+	}{
+		{name: "без команды", args: []string{"-db", db}, wantCode: 2},
+		{name: "неизвестная команда", args: []string{"-db", db, "import"}, wantCode: 2},
+		{name: "чтение несуществующей базы", args: []string{"-db", db, "list"}, wantCode: 1},
+		{name: "добавление", args: []string{"-db", db, "add", "Вал", "1,5"}, wantOutput: "1\n"},
+		{name: "добавление со ссылкой", args: []string{"-db", db, "add", "Сборка", "#1*2 + 30m"}, wantOutput: "2\n"},
+		{name: "добавление с ошибкой формулы", args: []string{"-db", db, "add", "Корпус", "2+"}, wantCode: 1},
+		{name: "добавление без наименования", args: []string{"-db", db, "add", " ", "2"}, wantCode: 2},
+		{name: "изменение формулы", args: []string{"-db", db, "update", "-time", "2", "1"}},
+		{name: "изменение устаревшей версии", args: []string{"-db", db, "update", "-name", "Вал 2", "-version", "1", "1"}, wantCode: 1},
+		{name: "изменение несуществующей записи", args: []string{"-db", db, "update", "-name", "Нет", "99"}, wantCode: 1},
+		{
+			name: "поиск",
+			args: []string{"-db", db, "search", "сбор"},
+			wantOutput: "ID  Наименование  Время, ч  Расчет времени\n" +
+				"2   Сборка        4.50      #1*2 + 30m\n",
+		},
+		{name: "выгрузка CSV", args: []string{"-db", db, "export", "-o", exported}},
+		{name: "удаление записи со ссылками", args: []string{"-db", db, "delete", "1"}, wantCode: 1},
+		{name: "удаление несуществующей записи", args: []string{"-db", db, "delete", "2", "99"}, wantCode: 1},
+		{name: "удаление", args: []string{"-db", db, "delete", "#2"}},
+		{name: "список JSON", args: []string{"-db", db, "list", "-json"}, wantOutput: `"name": "Вал"`, partial: true},
+		{name: "некорректный ID", args: []string{"-db", db, "delete", "один"}, wantCode: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCLI(tt.args, config.Default(), &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Fatalf("runCLI() = %d, want %d, stderr: %s", code, tt.wantCode, stderr.String())
+			}
+			if tt.wantCode != 0 {
+				if stderr.Len() == 0 {
+					t.Errorf("runCLI() не сообщил об ошибке")
+				}
+				return
+			}
+			if tt.partial && !strings.Contains(stdout.String(), tt.wantOutput) {
+				t.Errorf("runCLI() вывод = %q, want содержит %q", stdout.String(), tt.wantOutput)
+			}
+			if !tt.partial && stdout.String() != tt.wantOutput {
+				t.Errorf("runCLI() вывод = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+
+	csvData, err := os.ReadFile(exported)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	wantCSV := "ID,Наименование,Время обработки в часах,Расчет времени\n" +
+		"1,Вал,2,2\n" +
+		"2,Сборка,4.5,#1*2 + 30m\n"
+	if string(csvData) != wantCSV {
+		t.Errorf("выгрузка CSV = %q, want %q", csvData, wantCSV)
+	}
+
+	// Изменения сохранены в базе вместе с версиями
+	dbStorage := storage.NewSQLiteStorage().WithFilename(db)
+	defer dbStorage.Close()
+	products, err := dbStorage.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(products) != 1 || products[0].Name != "Вал" || products[0].Version != 2 {
+		t.Errorf("Load() = %v, want одна запись «Вал» версии 2", products)
+	}
+}
+
+func TestRunCLI_ExportJSON(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "database.xlsx")
+	if code := runCLI([]string{"-db", db, "add", "Вал", "1.5"}, config.Default(), &bytes.Buffer{}, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("runCLI(add) = %d", code)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"-db", db, "export", "-format", "json"}, config.Default(), &stdout, &stderr); code != 0 {
+		t.Fatalf("runCLI(export) = %d, stderr: %s", code, stderr.String())
+	}
+	var products []models.Product
+	if err := json.Unmarshal(stdout.Bytes(), &products); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(products) != 1 || products[0].Name != "Вал" || products[0].ProcessingTime != 1.5 {
+		t.Errorf("выгрузка JSON = %v, want запись «Вал» 1.5 ч", products)
+	}
+
+	if code := runCLI([]string{"-db", db, "export", "-format", "xml"}, config.Default(), &bytes.Buffer{}, &bytes.Buffer{}); code != 2 {
+		t.Errorf("runCLI(export -format xml) = %d, want 2", code)
+	}
+}
+
+func TestRunCLI_SettingsDatabase(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "database.db")
+	if code := runCLI([]string{"-db", db, "add", "Вал", "1.5"}, config.Default(), &bytes.Buffer{}, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("runCLI(add) = %d", code)
+	}
+
+	// Без -db используется последняя открытая база из настроек
+	settings := config.Default()
+	settings.UseDatabase(db)
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"list"}, settings, &stdout, &stderr); code != 0 {
+		t.Fatalf("runCLI(list) = %d, stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Вал") {
+		t.Errorf("runCLI(list) вывод = %q, want запись «Вал»", stdout.String())
+	}
+}
+
+func TestRunCLI_ShiftHours(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "database.db")
+
+	// Формулы рассчитываются с длительностью смены из настроек приложения
+	settings := config.Default()
+	settings.ShiftHours = 12
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"-db", db, "add", "Вал", "0,5 смены"}, settings, &bytes.Buffer{}, &stderr); code != 0 {
+		t.Fatalf("runCLI(add) = %d, stderr: %s", code, stderr.String())
+	}
+	if code := runCLI([]string{"-db", db, "list", "-json"}, settings, &stdout, &stderr); code != 0 {
+		t.Fatalf("runCLI(list) = %d, stderr: %s", code, stderr.String())
+	}
+	var products []models.Product
+	if err := json.Unmarshal(stdout.Bytes(), &products); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(products) != 1 || products[0].ProcessingTime != 6 {
+		t.Errorf("runCLI(list) = %v, want запись «Вал» 6 ч", products)
+	}
+}
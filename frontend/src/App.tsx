@@ -7,19 +7,23 @@ declare global {
     go?: unknown;
   }
 }
-import { GetProducts, SearchProducts, DeleteProducts } from "../wailsjs/go/main/App";
+import { GetProducts, SearchProducts, DeleteProducts, Undo, Redo, IsReadOnly, GetDatabasePath, OpenDatabase, NewDatabase, GetRecentDatabases, SwitchDatabase, GetSettings, GetSettingsError, UpdateSettings, GetLoadReport, GetLoadError, ReloadDatabase, GetTimeMismatches, SetShiftHours } from "../wailsjs/go/main/App";
 import { ProductTable } from "./components/ProductTable";
 import { AddProductDialog } from "./components/AddProductDialog";
 import { EditProductDialog } from "./components/EditProductDialog";
+import { LoadReportDialog } from "./components/LoadReportDialog";
+import { BackupsDialog } from "./components/BackupsDialog";
 import { Button } from "./components/ui/button";
 import { Input } from "./components/ui/input";
 import { ToastProvider } from "./components/ui/toast";
 import { Toaster } from "./components/Toaster";
 import { useToast } from "./hooks/use-toast";
-import { models } from "../wailsjs/go/models";
+import { config, main, models, storage } from "../wailsjs/go/models";
+import { EventsOn } from "../wailsjs/runtime/runtime";
 import { AlertDialog, AlertDialogAction, AlertDialogCancel, AlertDialogContent, AlertDialogDescription, AlertDialogFooter, AlertDialogHeader, AlertDialogTitle } from "./components/ui/alert-dialog";
 import { Switch } from "./components/ui/switch";
 import { Label } from "./components/ui/label";
+import { asConflict } from "./lib/errors";
 
 type Product = models.Product;
 
@@ -50,20 +54,25 @@ const waitForWailsRuntime = (): Promise<void> => {
 function App() {
   const [products, setProducts] = useState<Product[]>([]);
   const [isReady, setIsReady] = useState(false);
-  const [searchQuery, setSearchQuery] = useState(() => {
-    const savedSearchQuery = localStorage.getItem('searchQuery');
-    return savedSearchQuery || "";
-  });
+  const [readOnly, setReadOnly] = useState(false);
+  const [databasePath, setDatabasePath] = useState("");
+  const [recentDatabases, setRecentDatabases] = useState<string[]>([]);
+  const [settings, setSettings] = useState<config.Settings | null>(null);
+  const [shiftHours, setShiftHours] = useState("");
+  const [searchQuery, setSearchQuery] = useState("");
+  const [sortDirection, setSortDirection] = useState<'asc' | 'desc'>('asc');
   const [selectedProducts, setSelectedProducts] = useState<Record<number, boolean>>({});
   const [isAddDialogOpen, setIsAddDialogOpen] = useState(false);
   const [isEditDialogOpen, setIsEditDialogOpen] = useState(false);
   const [currentProduct, setCurrentProduct] = useState<Product | null>(null);
   const [isDeleteDialogOpen, setIsDeleteDialogOpen] = useState(false);
   const [selectedIdsToDelete, setSelectedIdsToDelete] = useState<number[]>([]);
-  const [filterBySelected, setFilterBySelected] = useState(() => {
-    const savedFilter = localStorage.getItem('filterBySelected');
-    return savedFilter ? JSON.parse(savedFilter) : false;
-  });
+  const [filterBySelected, setFilterBySelected] = useState(false);
+  const [loadReport, setLoadReport] = useState<storage.LoadReport | null>(null);
+  const [timeMismatches, setTimeMismatches] = useState<main.TimeMismatch[]>([]);
+  const [loadError, setLoadError] = useState("");
+  const [isReportDialogOpen, setIsReportDialogOpen] = useState(false);
+  const [isBackupsDialogOpen, setIsBackupsDialogOpen] = useState(false);
   const { toast } = useToast();
 
   // Ожидание готовности Wails runtime и загрузка продуктов
@@ -71,10 +80,31 @@ function App() {
     const init = async () => {
       await waitForWailsRuntime();
       setIsReady(true);
+      setReadOnly(await IsReadOnly());
+      setDatabasePath(await GetDatabasePath());
+      setRecentDatabases(await GetRecentDatabases() || []);
+      loadReportIssues();
+
+      // Поврежденный файл настроек переименован, настройки сброшены
+      const settingsError = await GetSettingsError();
+      if (settingsError) {
+        toast({
+          title: "Настройки сброшены",
+          description: settingsError,
+          variant: "destructive",
+        });
+      }
+
+      // Восстанавливаем настройки таблицы с прошлого запуска
+      const savedSettings = await GetSettings();
+      setSettings(savedSettings);
+      setShiftHours(String(savedSettings.shiftHours));
+      setSortDirection(savedSettings.view.sortDirection === 'desc' ? 'desc' : 'asc');
+      setFilterBySelected(savedSettings.view.filterBySelected);
       
       // Если есть сохраненный поисковый запрос, выполняем поиск
-      if (searchQuery) {
-        handleSearch(searchQuery);
+      if (savedSettings.view.searchQuery) {
+        handleSearch(savedSettings.view.searchQuery);
       } else {
         loadProducts();
       }
@@ -82,14 +112,90 @@ function App() {
     init();
   }, []);
 
-  // Сохранение состояния фильтрации при изменении
+  // Сохранение настроек таблицы при изменении. Запись откладывается,
+  // чтобы не сохранять настройки после каждой буквы поискового запроса
+  useEffect(() => {
+    if (!settings) return;
+    const view = { sortDirection, searchQuery, filterBySelected };
+    const timer = setTimeout(() => {
+      UpdateSettings(config.Settings.createFrom({ ...settings, view })).catch((error) =>
+        console.error("Ошибка сохранения настроек:", error)
+      );
+    }, 500);
+    return () => clearTimeout(timer);
+  }, [settings, sortDirection, searchQuery, filterBySelected]);
+
+  // Обновление таблицы, когда файл базы данных изменен другой программой
+  useEffect(() => {
+    if (!isReady) return;
+    const refresh = (description: string) => {
+      // После перечтения файла блокировка могла освободиться
+      IsReadOnly().then(setReadOnly);
+      loadReportIssues();
+      if (searchQuery) {
+        handleSearch(searchQuery);
+      } else {
+        loadProducts();
+      }
+      toast({ title: "Данные обновлены", description });
+    };
+    const offReloaded = EventsOn("products:reloaded", () =>
+      refresh("База данных изменена другой программой"),
+    );
+    const offChanged = EventsOn("products:changed", () =>
+      refresh("Данные изменены через HTTP API"),
+    );
+    return () => {
+      offReloaded();
+      offChanged();
+    };
+  }, [isReady, searchQuery]);
+
+  // Блокировку базы забрала другая копия приложения: изменения больше не сохранятся
   useEffect(() => {
-    localStorage.setItem('filterBySelected', JSON.stringify(filterBySelected));
-  }, [filterBySelected]);
+    if (!isReady) return;
+    return EventsOn("database:lock-lost", (reason: string) => {
+      setReadOnly(true);
+      toast({
+        title: "Изменения запрещены",
+        description: reason,
+        variant: "destructive",
+      });
+    });
+  }, [isReady]);
 
-  // Сохранение поискового запроса при изменении
+  // Отмена и повтор изменений: Ctrl+Z, Ctrl+Y или Ctrl+Shift+Z
   useEffect(() => {
-    localStorage.setItem('searchQuery', searchQuery);
+    const handleKeyDown = async (event: KeyboardEvent) => {
+      if (!(event.ctrlKey || event.metaKey)) return;
+      // В полях ввода сочетания работают как обычно
+      const target = event.target as HTMLElement;
+      if (target.tagName === "INPUT" || target.tagName === "TEXTAREA") return;
+
+      const key = event.key.toLowerCase();
+      const isUndo = (key === "z" || key === "я") && !event.shiftKey;
+      const isRedo = key === "y" || key === "н" || ((key === "z" || key === "я") && event.shiftKey);
+      if (!isUndo && !isRedo) return;
+
+      event.preventDefault();
+      try {
+        await (isUndo ? Undo() : Redo());
+        if (searchQuery) {
+          handleSearch(searchQuery);
+        } else {
+          loadProducts();
+        }
+      } catch (error) {
+        toast({
+          title: isUndo ? "Нельзя отменить" : "Нельзя повторить",
+          description: String(error),
+          variant: "destructive",
+        });
+      }
+    };
+
+    window.addEventListener("keydown", handleKeyDown);
+    return () => window.removeEventListener("keydown", handleKeyDown);
   }, [searchQuery]);
 
   // Загрузка всех продуктов
@@ -126,6 +232,77 @@ function App() {
     }
   };
 
+  // Загрузка отчета о проблемах последней загрузки базы данных
+  // и записей, время которых не совпало с формулой
+  const loadReportIssues = async () => {
+    try {
+      setLoadReport(await GetLoadReport());
+      setTimeMismatches(await GetTimeMismatches() || []);
+      setLoadError(await GetLoadError());
+    } catch (error) {
+      console.error("Ошибка загрузки отчета:", error);
+    }
+  };
+
+  // Обновление данных после смены базы данных
+  const refreshDatabase = async () => {
+    setDatabasePath(await GetDatabasePath());
+    setRecentDatabases(await GetRecentDatabases() || []);
+    setReadOnly(await IsReadOnly());
+    loadReportIssues();
+    localStorage.removeItem('selectedProducts');
+    if (searchQuery) {
+      handleSearch(searchQuery);
+    } else {
+      loadProducts();
+    }
+  };
+
+  // Открытие существующей или создание новой базы данных
+  const handleOpenDatabase = async (create: boolean) => {
+    try {
+      const path = await (create ? NewDatabase() : OpenDatabase());
+      // Пустой путь означает, что выбор файла отменен
+      if (!path) return;
+      await refreshDatabase();
+    } catch (error) {
+      toast({
+        title: create ? "Не удалось создать базу" : "Не удалось открыть базу",
+        description: String(error),
+        variant: "destructive",
+      });
+    }
+  };
+
+  // Переключение на базу данных из списка недавних
+  const handleSwitchDatabase = async (path: string) => {
+    if (path === databasePath) return;
+    try {
+      await SwitchDatabase(path);
+    } catch (error) {
+      toast({
+        title: "Не удалось открыть базу",
+        description: String(error),
+        variant: "destructive",
+      });
+    }
+    await refreshDatabase();
+  };
+
+  // Повторная загрузка базы, которую не удалось прочитать
+  const handleReloadDatabase = async () => {
+    try {
+      await ReloadDatabase();
+    } catch (error) {
+      toast({
+        title: "Не удалось загрузить базу",
+        description: String(error),
+        variant: "destructive",
+      });
+    }
+    await refreshDatabase();
+  };
+
   // Поиск продуктов
   const handleSearch = async (query: string) => {
     try {
@@ -177,7 +354,17 @@ function App() {
   // Удаление выбранных продуктов
   const handleDeleteSelected = async () => {
     try {
-      await DeleteProducts(selectedIdsToDelete);
+      // Версии записей, которые видел пользователь: если запись с тех пор
+      // изменили или удалили, удаление отклоняется. Записи, скрытые поиском,
+      // берутся из полного списка
+      const known = selectedIdsToDelete.every((id) => products.some((p) => p.id === id))
+        ? products
+        : await GetProducts();
+      const versions: Record<number, number> = {};
+      selectedIdsToDelete.forEach((id) => {
+        versions[id] = known.find((p) => p.id === id)?.version ?? 0;
+      });
+      await DeleteProducts(versions);
       toast({
         title: "Успешно",
         description: `Удалено записей: ${selectedIdsToDelete.length}`,
@@ -185,9 +372,23 @@ function App() {
       setIsDeleteDialogOpen(false);
       loadProducts();
     } catch (error) {
+      const conflict = asConflict(error);
+      if (conflict) {
+        const current = conflict.current
+          ? `Сейчас: «${conflict.current.name}», ${conflict.current.timeCalculation}.`
+          : "Запись уже удалена.";
+        toast({
+          title: "Конфликт изменений",
+          description: `${conflict.message}. ${current} Список обновлен, проверьте записи и повторите удаление.`,
+          variant: "destructive",
+        });
+        setIsDeleteDialogOpen(false);
+        loadProducts();
+        return;
+      }
       toast({
         title: "Ошибка",
-        description: "Не удалось удалить записи",
+        description: `Не удалось удалить записи: ${error}`,
         variant: "destructive",
       });
     }
@@ -227,6 +428,34 @@ function App() {
     loadProducts();
   };
 
+  // Обновление после исправлений в отчете о загрузке
+  const handleReportChange = () => {
+    loadReportIssues();
+    loadProducts();
+  };
+
+  // Изменение длительности смены пересчитывает время продуктов с формулами
+  const handleShiftHours = async () => {
+    const hours = Number(shiftHours.replace(",", "."));
+    if (!settings || hours === settings.shiftHours) return;
+    try {
+      await SetShiftHours(hours);
+      setSettings(config.Settings.createFrom({ ...settings, shiftHours: hours }));
+      setShiftHours(String(hours));
+      loadProducts();
+    } catch (error) {
+      setShiftHours(String(settings.shiftHours));
+      toast({
+        title: "Ошибка",
+        description: String(error),
+        variant: "destructive",
+      });
+    }
+  };
+
+  // Количество проблем последней загрузки
+  const reportSize = (loadReport ? loadReport.issues.length + loadReport.quarantine.length : 0) + timeMismatches.length;
+
   // Фильтрация продуктов
   const filteredProducts = filterBySelected && Object.values(selectedProducts).some(value => value)
     ? products.filter(product => selectedProducts[product.id])
@@ -246,7 +475,68 @@ function App() {
       <div className="flex flex-col h-screen overflow-hidden">
         <div className="sticky top-0 bg-white z-20 border-b shadow-sm">
           <div className="container mx-auto py-3 px-4">
-            <h1 className="text-2xl font-bold mb-3">Редактор базы данных</h1>
+            <h1 className="text-2xl font-bold mb-1">
+              Редактор базы данных
+              {readOnly && (
+                <span className="ml-3 align-middle text-sm font-normal text-muted-foreground">
+                  только просмотр
+                </span>
+              )}
+            </h1>
+            <div className="flex items-center gap-2 mb-3 text-sm text-muted-foreground">
+              <select
+                value={databasePath}
+                onChange={(e) => handleSwitchDatabase(e.target.value)}
+                title={databasePath}
+                className="h-9 min-w-0 max-w-md truncate rounded-md border border-input bg-background px-2 text-sm"
+              >
+                {!recentDatabases.includes(databasePath) && (
+                  <option value={databasePath}>{databasePath}</option>
+                )}
+                {recentDatabases.map((path) => (
+                  <option key={path} value={path}>{path}</option>
+                ))}
+              </select>
+              <Button variant="outline" size="sm" onClick={() => handleOpenDatabase(false)}>
+                Открыть базу
+              </Button>
+              <Button variant="outline" size="sm" onClick={() => handleOpenDatabase(true)}>
+                Новая база
+              </Button>
+              {!readOnly && (
+                <Button variant="outline" size="sm" onClick={() => setIsBackupsDialogOpen(true)}>
+                  Резервные копии
+                </Button>
+              )}
+              <Label htmlFor="shift-hours" className="ml-2 whitespace-nowrap">Смена, ч</Label>
+              <Input
+                id="shift-hours"
+                value={shiftHours}
+                onChange={(e) => setShiftHours(e.target.value)}
+                onBlur={handleShiftHours}
+                onKeyDown={(e) => e.key === "Enter" && e.currentTarget.blur()}
+                disabled={readOnly}
+                title="Длительность смены для единицы «смена» в формулах"
+                className="h-9 w-16"
+              />
+              {reportSize > 0 && (
+                <Button variant="outline" size="sm" onClick={() => setIsReportDialogOpen(true)}>
+                  Проблемы загрузки ({reportSize})
+                </Button>
+              )}
+            </div>
+
+            {loadError && (
+              <div className="flex items-center gap-3 mb-3 rounded-md border border-destructive p-3 text-sm text-destructive">
+                <span>
+                  {loadError}. Изменения запрещены, пока база не будет загружена: исправьте файл
+                  и загрузите его снова или откройте другую базу.
+                </span>
+                <Button variant="outline" size="sm" className="ml-auto" onClick={handleReloadDatabase}>
+                  Загрузить снова
+                </Button>
+              </div>
+            )}
             
             <div className="flex flex-col md:flex-row gap-3 mb-1">
               <Input
@@ -264,9 +554,11 @@ function App() {
                 <Label htmlFor="filter-selected">Показать выбранные</Label>
               </div>
               <div className="flex gap-2 ml-auto">
-                <Button onClick={() => setIsAddDialogOpen(true)}>
-                  Добавить запись
-                </Button>
+                {!readOnly && (
+                  <Button onClick={() => setIsAddDialogOpen(true)}>
+                    Добавить запись
+                  </Button>
+                )}
                 <Button variant="outline" onClick={handleClearSelection}>
                   Снять выделение
                 </Button>
@@ -283,6 +575,9 @@ function App() {
               onSelect={handleSelectProduct}
               onEdit={handleEdit}
               onDelete={prepareDeleteSelected}
+              readOnly={readOnly}
+              sortDirection={sortDirection}
+              onToggleSort={() => setSortDirection(prev => prev === 'asc' ? 'desc' : 'asc')}
             />
           </div>
         </div>
@@ -302,6 +597,23 @@ function App() {
           />
         )}
 
+        {loadReport && (
+          <LoadReportDialog
+            isOpen={isReportDialogOpen}
+            onClose={() => setIsReportDialogOpen(false)}
+            onSuccess={handleReportChange}
+            report={loadReport}
+            mismatches={timeMismatches}
+            readOnly={readOnly}
+          />
+        )}
+
+        <BackupsDialog
+          isOpen={isBackupsDialogOpen}
+          onClose={() => setIsBackupsDialogOpen(false)}
+          onSuccess={handleReportChange}
+        />
+
         <AlertDialog open={isDeleteDialogOpen} onOpenChange={setIsDeleteDialogOpen}>
           <AlertDialogContent>
             <AlertDialogHeader>
@@ -10,6 +10,7 @@ import {
 } from "./ui/dialog";
 import { Input } from "./ui/input";
 import { useToast } from "../hooks/use-toast";
+import { FormulaHint } from "./FormulaHint";
 
 interface AddProductDialogProps {
   isOpen: boolean;
@@ -25,6 +26,7 @@ export function AddProductDialog({
   const [name, setName] = useState("");
   const [timeCalculation, setTimeCalculation] = useState("");
   const [isSubmitting, setIsSubmitting] = useState(false);
+  const [isFormulaValid, setIsFormulaValid] = useState(true);
   const { toast } = useToast();
 
   const handleSubmit = async () => {
@@ -49,7 +51,7 @@ export function AddProductDialog({
     } catch (error) {
       toast({
         title: "Ошибка",
-        description: "Не удалось добавить запись",
+        description: `Не удалось добавить запись: ${error}`,
         variant: "destructive",
       });
     } finally {
@@ -90,7 +92,11 @@ export function AddProductDialog({
               id="timeCalculation"
               value={timeCalculation}
               onChange={(e) => setTimeCalculation(e.target.value)}
-              placeholder="Например: 8+2+5"
+              placeholder="Например: 30мин + 1,5ч + 2*3"
+            />
+            <FormulaHint
+              formula={timeCalculation}
+              onValidChange={setIsFormulaValid}
             />
           </div>
         </div>
@@ -99,7 +105,7 @@ export function AddProductDialog({
           <Button variant="outline" onClick={handleClose}>
             Отмена
           </Button>
-          <Button onClick={handleSubmit} disabled={isSubmitting}>
+          <Button onClick={handleSubmit} disabled={isSubmitting || !isFormulaValid}>
             {isSubmitting ? "Сохранение..." : "Сохранить"}
           </Button>
         </DialogFooter>
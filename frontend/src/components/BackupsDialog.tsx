@@ -0,0 +1,102 @@
+import { useEffect, useState } from "react";
+import { ListBackups, RestoreBackup } from "../../wailsjs/go/main/App";
+import { storage } from "../../wailsjs/go/models";
+import { Button } from "./ui/button";
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogHeader,
+  DialogTitle,
+} from "./ui/dialog";
+import { useToast } from "../hooks/use-toast";
+
+interface BackupsDialogProps {
+  isOpen: boolean;
+  onClose: () => void;
+  onSuccess: () => void;
+}
+
+export function BackupsDialog({ isOpen, onClose, onSuccess }: BackupsDialogProps) {
+  const [backups, setBackups] = useState<storage.Backup[]>([]);
+  const [loadError, setLoadError] = useState("");
+  const [pendingId, setPendingId] = useState<string | null>(null);
+  const { toast } = useToast();
+
+  // Список копий перечитывается при каждом открытии: копии создаются при сохранении
+  useEffect(() => {
+    if (!isOpen) return;
+    setLoadError("");
+    ListBackups()
+      .then((list) => setBackups(list || []))
+      .catch((error) => {
+        setBackups([]);
+        setLoadError(String(error));
+      });
+  }, [isOpen]);
+
+  const handleRestore = async (backup: storage.Backup) => {
+    setPendingId(backup.id);
+    try {
+      await RestoreBackup(backup.id);
+      toast({
+        title: "Успешно",
+        description: `База восстановлена из копии от ${formatDate(backup.createdAt)}`,
+      });
+      onSuccess();
+      onClose();
+    } catch (error) {
+      toast({
+        title: "Ошибка",
+        description: `Не удалось восстановить копию: ${error}`,
+        variant: "destructive",
+      });
+    } finally {
+      setPendingId(null);
+    }
+  };
+
+  return (
+    <Dialog open={isOpen} onOpenChange={onClose}>
+      <DialogContent className="max-w-xl max-h-[80vh] overflow-y-auto">
+        <DialogHeader>
+          <DialogTitle>Резервные копии</DialogTitle>
+          <DialogDescription>
+            Перед восстановлением текущая база тоже сохраняется в резервную копию.
+          </DialogDescription>
+        </DialogHeader>
+
+        {loadError && <div className="text-sm text-destructive">{loadError}</div>}
+        {!loadError && backups.length === 0 && (
+          <div className="text-sm text-muted-foreground">Резервных копий пока нет</div>
+        )}
+        <div className="grid gap-2">
+          {backups.map((backup) => (
+            <div key={backup.id} className="flex items-center gap-3 rounded-md border p-2 text-sm">
+              <span className="font-medium">{formatDate(backup.createdAt)}</span>
+              <span className="text-muted-foreground">{formatSize(backup.size)}</span>
+              <Button
+                variant="outline"
+                size="sm"
+                className="ml-auto"
+                disabled={pendingId !== null}
+                onClick={() => handleRestore(backup)}
+              >
+                {pendingId === backup.id ? "Восстановление..." : "Восстановить"}
+              </Button>
+            </div>
+          ))}
+        </div>
+      </DialogContent>
+    </Dialog>
+  );
+}
+
+// Время создания копии приходит из Go строкой в формате RFC 3339
+function formatDate(createdAt: unknown): string {
+  return new Date(String(createdAt)).toLocaleString("ru-RU");
+}
+
+function formatSize(size: number): string {
+  return size < 1024 ? `${size} Б` : `${(size / 1024).toFixed(1)} КБ`;
+}
@@ -11,6 +11,8 @@ import {
 } from "./ui/dialog";
 import { Input } from "./ui/input";
 import { useToast } from "../hooks/use-toast";
+import { FormulaHint } from "./FormulaHint";
+import { asConflict } from "../lib/errors";
 
 type Product = models.Product;
 
@@ -30,6 +32,7 @@ export function EditProductDialog({
   const [name, setName] = useState("");
   const [timeCalculation, setTimeCalculation] = useState("");
   const [isSubmitting, setIsSubmitting] = useState(false);
+  const [isFormulaValid, setIsFormulaValid] = useState(true);
   const { toast } = useToast();
 
   useEffect(() => {
@@ -51,7 +54,7 @@ export function EditProductDialog({
 
     setIsSubmitting(true);
     try {
-      await UpdateProduct(product.id, name, timeCalculation);
+      await UpdateProduct(product.id, product.version, name, timeCalculation);
       toast({
         title: "Успешно",
         description: "Запись обновлена",
@@ -59,9 +62,24 @@ export function EditProductDialog({
       onSuccess();
       onClose();
     } catch (error) {
+      // Запись изменили или удалили другие: показываем актуальные данные
+      const conflict = asConflict(error);
+      if (conflict) {
+        const current = conflict.current
+          ? `Сейчас: «${conflict.current.name}», ${conflict.current.timeCalculation}.`
+          : "Запись удалена.";
+        toast({
+          title: "Конфликт изменений",
+          description: `${conflict.message}. ${current} Список обновлен, проверьте запись и повторите изменение.`,
+          variant: "destructive",
+        });
+        onSuccess();
+        onClose();
+        return;
+      }
       toast({
         title: "Ошибка",
-        description: "Не удалось обновить запись",
+        description: `Не удалось обновить запись: ${error}`,
         variant: "destructive",
       });
     } finally {
@@ -95,7 +113,11 @@ export function EditProductDialog({
               id="edit-time"
               value={timeCalculation}
               onChange={(e) => setTimeCalculation(e.target.value)}
-              placeholder="Например: 8+2+5"
+              placeholder="Например: 30мин + 1,5ч + 2*3"
+            />
+            <FormulaHint
+              formula={timeCalculation}
+              onValidChange={setIsFormulaValid}
             />
           </div>
         </div>
@@ -104,7 +126,7 @@ export function EditProductDialog({
           <Button variant="outline" onClick={onClose}>
             Отмена
           </Button>
-          <Button onClick={handleSubmit} disabled={isSubmitting}>
+          <Button onClick={handleSubmit} disabled={isSubmitting || !isFormulaValid}>
             {isSubmitting ? "Сохранение..." : "Сохранить"}
           </Button>
         </DialogFooter>
@@ -0,0 +1,51 @@
+import { useEffect, useState } from "react";
+import { ValidateFormula } from "../../wailsjs/go/main/App";
+import { main } from "../../wailsjs/go/models";
+
+interface FormulaHintProps {
+  formula: string;
+  onValidChange?: (valid: boolean) => void;
+}
+
+// Подсказка под полем формулы: результат расчета или описание ошибки
+export function FormulaHint({ formula, onValidChange }: FormulaHintProps) {
+  const [result, setResult] = useState<main.FormulaValidation | null>(null);
+
+  useEffect(() => {
+    let cancelled = false;
+    const timer = setTimeout(async () => {
+      try {
+        const validation = await ValidateFormula(formula);
+        if (!cancelled) {
+          setResult(validation);
+          onValidChange?.(validation.valid);
+        }
+      } catch (error) {
+        console.error("Ошибка проверки формулы:", error);
+      }
+    }, 200);
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [formula]);
+
+  if (!result || !formula.trim()) {
+    return null;
+  }
+
+  if (!result.valid && result.error) {
+    return (
+      <p className="text-sm text-destructive">
+        Позиция {result.error.position}: {result.error.message}
+        {result.error.token ? ` «${result.error.token}»` : ""}
+      </p>
+    );
+  }
+
+  return (
+    <p className="text-sm text-muted-foreground">
+      = {result.processingTime} ч
+    </p>
+  );
+}
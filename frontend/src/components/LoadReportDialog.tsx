@@ -0,0 +1,257 @@
+import { useEffect, useState } from "react";
+import { DiscardQuarantined, FixTimeMismatches, ImportQuarantined } from "../../wailsjs/go/main/App";
+import { main, storage } from "../../wailsjs/go/models";
+import { Button } from "./ui/button";
+import {
+  Dialog,
+  DialogContent,
+  DialogFooter,
+  DialogHeader,
+  DialogTitle,
+} from "./ui/dialog";
+import { Input } from "./ui/input";
+import { useToast } from "../hooks/use-toast";
+
+interface LoadReportDialogProps {
+  isOpen: boolean;
+  onClose: () => void;
+  onSuccess: () => void;
+  report: storage.LoadReport;
+  mismatches: main.TimeMismatch[];
+  readOnly: boolean;
+}
+
+// Исправленные значения строки карантина
+interface RowDraft {
+  name: string;
+  timeCalculation: string;
+}
+
+export function LoadReportDialog({
+  isOpen,
+  onClose,
+  onSuccess,
+  report,
+  mismatches,
+  readOnly,
+}: LoadReportDialogProps) {
+  const [drafts, setDrafts] = useState<Record<string, RowDraft>>({});
+  const [pendingKey, setPendingKey] = useState<string | null>(null);
+  const [isFixing, setIsFixing] = useState(false);
+  const { toast } = useToast();
+
+  // Ошибки в формулах исправляются только изменением формулы продукта
+  const timeMismatches = mismatches.filter((mismatch) => !mismatch.error);
+  const formulaErrors = mismatches.filter((mismatch) => mismatch.error);
+
+  // Поля заполняются исходными значениями строк: наименование и формула,
+  // а если формулы нет, то время обработки
+  useEffect(() => {
+    const initial: Record<string, RowDraft> = {};
+    report.quarantine.forEach((row) => {
+      initial[row.key] = {
+        name: row.cells[1] || "",
+        timeCalculation: row.cells[3] || row.cells[2] || "",
+      };
+    });
+    setDrafts(initial);
+  }, [report]);
+
+  const updateDraft = (key: string, change: Partial<RowDraft>) => {
+    setDrafts((prev) => ({ ...prev, [key]: { ...prev[key], ...change } }));
+  };
+
+  const handleImport = async (row: storage.QuarantinedRow) => {
+    const draft = drafts[row.key];
+    if (!draft?.name.trim()) {
+      toast({
+        title: "Ошибка",
+        description: "Наименование не может быть пустым",
+        variant: "destructive",
+      });
+      return;
+    }
+
+    setPendingKey(row.key);
+    try {
+      await ImportQuarantined(row.key, draft.name, draft.timeCalculation);
+      toast({
+        title: "Успешно",
+        description: `Строка ${row.row} добавлена в реестр`,
+      });
+      onSuccess();
+    } catch (error) {
+      toast({
+        title: "Ошибка",
+        description: `Не удалось добавить строку: ${error}`,
+        variant: "destructive",
+      });
+    } finally {
+      setPendingKey(null);
+    }
+  };
+
+  const handleDiscard = async (row: storage.QuarantinedRow) => {
+    setPendingKey(row.key);
+    try {
+      await DiscardQuarantined(row.key);
+      onSuccess();
+    } catch (error) {
+      toast({
+        title: "Ошибка",
+        description: `Не удалось удалить строку: ${error}`,
+        variant: "destructive",
+      });
+    } finally {
+      setPendingKey(null);
+    }
+  };
+
+  const handleFixAll = async () => {
+    setIsFixing(true);
+    try {
+      await FixTimeMismatches();
+      toast({
+        title: "Успешно",
+        description: `Исправлено записей: ${timeMismatches.length}`,
+      });
+      onSuccess();
+    } catch (error) {
+      toast({
+        title: "Ошибка",
+        description: `Не удалось исправить время: ${error}`,
+        variant: "destructive",
+      });
+    } finally {
+      setIsFixing(false);
+    }
+  };
+
+  return (
+    <Dialog open={isOpen} onOpenChange={onClose}>
+      <DialogContent className="max-w-3xl max-h-[80vh] overflow-y-auto">
+        <DialogHeader>
+          <DialogTitle>Проблемы загрузки</DialogTitle>
+        </DialogHeader>
+
+        {report.quarantine.length > 0 && (
+          <div className="grid gap-3">
+            <h3 className="text-sm font-medium">
+              Не загружены ({report.quarantine.length})
+            </h3>
+            {report.quarantine.map((row) => (
+              <div key={row.key} className="grid gap-2 rounded-md border p-3">
+                <div className="text-sm">
+                  <span className="font-medium">
+                    {row.sheet}, строка {row.row}:
+                  </span>{" "}
+                  {row.reason}
+                </div>
+                <div className="text-xs text-muted-foreground truncate">
+                  {row.cells.filter((cell) => cell !== "").join(" | ")}
+                </div>
+                {!readOnly && (
+                  <div className="flex flex-col md:flex-row gap-2">
+                    <Input
+                      value={drafts[row.key]?.name ?? ""}
+                      onChange={(e) => updateDraft(row.key, { name: e.target.value })}
+                      placeholder="Наименование"
+                    />
+                    <Input
+                      value={drafts[row.key]?.timeCalculation ?? ""}
+                      onChange={(e) => updateDraft(row.key, { timeCalculation: e.target.value })}
+                      placeholder="Время обработки"
+                    />
+                    <Button
+                      size="sm"
+                      onClick={() => handleImport(row)}
+                      disabled={pendingKey !== null}
+                    >
+                      Добавить
+                    </Button>
+                    <Button
+                      size="sm"
+                      variant="outline"
+                      onClick={() => handleDiscard(row)}
+                      disabled={pendingKey !== null}
+                    >
+                      Удалить
+                    </Button>
+                  </div>
+                )}
+              </div>
+            ))}
+          </div>
+        )}
+
+        {timeMismatches.length > 0 && (
+          <div className="grid gap-2">
+            <div className="flex items-center justify-between gap-2">
+              <h3 className="text-sm font-medium">
+                Время не совпадает с формулой ({timeMismatches.length})
+              </h3>
+              {!readOnly && (
+                <Button size="sm" onClick={handleFixAll} disabled={isFixing}>
+                  {isFixing ? "Сохранение..." : "Исправить все"}
+                </Button>
+              )}
+            </div>
+            <ul className="grid gap-1 text-sm">
+              {timeMismatches.map((mismatch) => (
+                <li key={mismatch.id}>
+                  <span className="font-medium">#{mismatch.id} {mismatch.name}</span>
+                  <span className="text-muted-foreground"> {mismatch.timeCalculation}</span>
+                  : в файле {mismatch.storedTime} ч, по формуле {mismatch.calculatedTime} ч
+                </li>
+              ))}
+            </ul>
+          </div>
+        )}
+
+        {formulaErrors.length > 0 && (
+          <div className="grid gap-2">
+            <h3 className="text-sm font-medium">
+              Ошибки в формулах ({formulaErrors.length})
+            </h3>
+            <ul className="grid gap-1 text-sm">
+              {formulaErrors.map((mismatch) => (
+                <li key={mismatch.id}>
+                  <span className="font-medium">#{mismatch.id} {mismatch.name}</span>
+                  <span className="text-muted-foreground"> {mismatch.timeCalculation}</span>
+                  : {mismatch.error}, в файле {mismatch.storedTime} ч
+                </li>
+              ))}
+            </ul>
+          </div>
+        )}
+
+        {report.issues.length > 0 && (
+          <div className="grid gap-2">
+            <h3 className="text-sm font-medium">
+              Замечания ({report.issues.length})
+            </h3>
+            <ul className="grid gap-1 text-sm">
+              {report.issues.map((issue, index) => (
+                <li key={index}>
+                  <span className="font-medium">
+                    {issue.sheet}!{issue.column}{issue.row}
+                  </span>
+                  {issue.value && (
+                    <span className="text-muted-foreground"> «{issue.value}»</span>
+                  )}
+                  : {issue.reason}
+                </li>
+              ))}
+            </ul>
+          </div>
+        )}
+
+        <DialogFooter>
+          <Button variant="outline" onClick={onClose}>
+            Закрыть
+          </Button>
+        </DialogFooter>
+      </DialogContent>
+    </Dialog>
+  );
+}
@@ -2,7 +2,6 @@ import { Pencil, ArrowUpDown, Trash2 } from "lucide-react";
 import { Button } from "./ui/button";
 import { Checkbox } from "./ui/checkbox";
 import { models } from "../../wailsjs/go/models";
-import { useState, useEffect } from "react";
 
 type Product = models.Product;
 
@@ -12,6 +11,11 @@ interface ProductTableProps {
   onSelect: (id: number, isSelected: boolean) => void;
   onEdit: (product: Product) => void;
   onDelete: () => void;
+  // В режиме только для чтения кнопки изменения скрыты
+  readOnly?: boolean;
+  // Направление сортировки хранится в настройках приложения
+  sortDirection: 'asc' | 'desc';
+  onToggleSort: () => void;
 }
 
 export function ProductTable({
@@ -20,21 +24,10 @@ export function ProductTable({
   onSelect,
   onEdit,
   onDelete,
+  readOnly = false,
+  sortDirection,
+  onToggleSort,
 }: ProductTableProps) {
-  const [sortDirection, setSortDirection] = useState<'asc' | 'desc'>(() => {
-    const savedSortDirection = localStorage.getItem('sortDirection');
-    return savedSortDirection ? (savedSortDirection as 'asc' | 'desc') : 'asc';
-  });
-  
-  // Сохранение состояния сортировки при изменении
-  useEffect(() => {
-    localStorage.setItem('sortDirection', sortDirection);
-  }, [sortDirection]);
-  
-  const toggleSort = () => {
-    setSortDirection(prev => prev === 'asc' ? 'desc' : 'asc');
-  };
-  
   const sortedProducts = [...products].sort((a, b) => {
     if (sortDirection === 'asc') {
       return a.id - b.id;
@@ -57,32 +50,36 @@ export function ProductTable({
           <thead className="sticky-header">
             <tr>
               <th className="w-[50px] px-4 py-2 text-center font-medium text-muted-foreground">
-                <Button
-                  variant={hasSelectedProducts ? "destructive" : "ghost"}
-                  size="icon"
-                  onClick={onDelete}
-                  disabled={!hasSelectedProducts}
-                  className={`h-7 w-7 ${!hasSelectedProducts ? 'text-gray-300 hover:text-gray-300 hover:bg-transparent' : ''}`}
-                >
-                  <Trash2 className="h-4 w-4" />
-                </Button>
+                {!readOnly && (
+                  <Button
+                    variant={hasSelectedProducts ? "destructive" : "ghost"}
+                    size="icon"
+                    onClick={onDelete}
+                    disabled={!hasSelectedProducts}
+                    className={`h-7 w-7 ${!hasSelectedProducts ? 'text-gray-300 hover:text-gray-300 hover:bg-transparent' : ''}`}
+                  >
+                    <Trash2 className="h-4 w-4" />
+                  </Button>
+                )}
               </th>
               <th className="px-4 py-2 text-left font-medium text-muted-foreground">
                 <div className="flex items-center">
                   Наименование
-                  <Button variant="ghost" size="sm" onClick={toggleSort} className="ml-2 h-7 w-7 p-0">
+                  <Button variant="ghost" size="sm" onClick={onToggleSort} className="ml-2 h-7 w-7 p-0">
                     <ArrowUpDown className="h-4 w-4" />
                   </Button>
                 </div>
               </th>
               <th className="w-[150px] px-4 py-2 text-center font-medium text-muted-foreground whitespace-nowrap">Время обработки</th>
-              <th className="w-[80px] px-4 py-2 text-center font-medium text-muted-foreground">Действия</th>
+              {!readOnly && (
+                <th className="w-[80px] px-4 py-2 text-center font-medium text-muted-foreground">Действия</th>
+              )}
             </tr>
           </thead>
           <tbody>
             {!products || products.length === 0 ? (
               <tr>
-                <td colSpan={4} className="text-center py-8 text-lg">
+                <td colSpan={readOnly ? 3 : 4} className="text-center py-8 text-lg">
                   Нет данных для отображения
                 </td>
               </tr>
@@ -103,21 +100,23 @@ export function ProductTable({
                   </td>
                   <td className="px-4 py-3 font-medium">{product.name}</td>
                   <td className="px-4 py-3 text-center">{product.processingTime.toFixed(2)} ч.</td>
-                  <td className="px-4 py-3">
-                    <div className="flex justify-center">
-                      <Button
-                        variant="ghost"
-                        size="icon"
-                        onClick={(e) => {
-                          e.stopPropagation();
-                          onEdit(product);
-                        }}
-                        className="h-7 w-7"
-                      >
-                        <Pencil className="h-4 w-4" />
-                      </Button>
-                    </div>
-                  </td>
+                  {!readOnly && (
+                    <td className="px-4 py-3">
+                      <div className="flex justify-center">
+                        <Button
+                          variant="ghost"
+                          size="icon"
+                          onClick={(e) => {
+                            e.stopPropagation();
+                            onEdit(product);
+                          }}
+                          className="h-7 w-7"
+                        >
+                          <Pencil className="h-4 w-4" />
+                        </Button>
+                      </div>
+                    </td>
+                  )}
                 </tr>
               ))
             )}
@@ -0,0 +1,20 @@
+import { models } from "../../wailsjs/go/models";
+
+// Код конфликта версий, совпадает с ErrorCodeConflict в app.go
+export const ERROR_CODE_CONFLICT = "conflict";
+
+// Ошибка метода приложения с кодом, совпадает с AppError в app.go.
+// Остальные ошибки приходят из Go строкой.
+export interface AppError {
+  code: string;
+  message: string;
+  current: models.Product | null;
+}
+
+// Возвращает конфликт версий или null, если ошибка другая
+export function asConflict(error: unknown): AppError | null {
+  if (typeof error === "object" && error !== null && (error as AppError).code === ERROR_CODE_CONFLICT) {
+    return error as AppError;
+  }
+  return null;
+}
@@ -1,15 +1,73 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {config} from '../models';
+import {history} from '../models';
+import {main} from '../models';
 import {models} from '../models';
+import {storage} from '../models';
+
+export function AddConstant(arg1:string,arg2:number):Promise<void>;
 
 export function AddProduct(arg1:string,arg2:string):Promise<void>;
 
-export function DeleteProduct(arg1:number):Promise<void>;
+export function DeleteConstant(arg1:string):Promise<void>;
+
+export function DeleteProduct(arg1:number,arg2:number):Promise<void>;
+
+export function DeleteProducts(arg1:{[key: number]: number}):Promise<void>;
+
+export function DiscardQuarantined(arg1:string):Promise<void>;
+
+export function FixTimeMismatches():Promise<void>;
+
+export function GetConstants():Promise<Array<models.Constant>>;
+
+export function GetDatabasePath():Promise<string>;
 
-export function DeleteProducts(arg1:Array<number>):Promise<void>;
+export function GetHistoryState():Promise<history.State>;
+
+export function GetLoadError():Promise<string>;
+
+export function GetLoadReport():Promise<storage.LoadReport>;
 
 export function GetProducts():Promise<Array<models.Product>>;
 
+export function GetRecentDatabases():Promise<Array<string>>;
+
+export function GetSettings():Promise<config.Settings>;
+
+export function GetSettingsError():Promise<string>;
+
+export function GetTimeMismatches():Promise<Array<main.TimeMismatch>>;
+
+export function ImportQuarantined(arg1:string,arg2:string,arg3:string):Promise<void>;
+
+export function IsReadOnly():Promise<boolean>;
+
+export function ListBackups():Promise<Array<storage.Backup>>;
+
+export function NewDatabase():Promise<string>;
+
+export function OpenDatabase():Promise<string>;
+
+export function Redo():Promise<void>;
+
+export function ReloadDatabase():Promise<void>;
+
+export function RestoreBackup(arg1:string):Promise<void>;
+
 export function SearchProducts(arg1:string):Promise<Array<models.Product>>;
 
-export function UpdateProduct(arg1:number,arg2:string,arg3:string):Promise<void>;
+export function SetShiftHours(arg1:number):Promise<void>;
+
+export function SwitchDatabase(arg1:string):Promise<void>;
+
+export function Undo():Promise<void>;
+
+export function UpdateConstant(arg1:string,arg2:number):Promise<void>;
+
+export function UpdateProduct(arg1:number,arg2:number,arg3:string,arg4:string):Promise<void>;
+
+export function UpdateSettings(arg1:config.Settings):Promise<void>;
+
+export function ValidateFormula(arg1:string):Promise<main.FormulaValidation>;
@@ -2,26 +2,134 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddConstant(arg1, arg2) {
+  return window['go']['main']['App']['AddConstant'](arg1, arg2);
+}
+
 export function AddProduct(arg1, arg2) {
   return window['go']['main']['App']['AddProduct'](arg1, arg2);
 }
 
-export function DeleteProduct(arg1) {
-  return window['go']['main']['App']['DeleteProduct'](arg1);
+export function DeleteConstant(arg1) {
+  return window['go']['main']['App']['DeleteConstant'](arg1);
+}
+
+export function DeleteProduct(arg1, arg2) {
+  return window['go']['main']['App']['DeleteProduct'](arg1, arg2);
 }
 
 export function DeleteProducts(arg1) {
   return window['go']['main']['App']['DeleteProducts'](arg1);
 }
 
+export function DiscardQuarantined(arg1) {
+  return window['go']['main']['App']['DiscardQuarantined'](arg1);
+}
+
+export function FixTimeMismatches() {
+  return window['go']['main']['App']['FixTimeMismatches']();
+}
+
+export function GetConstants() {
+  return window['go']['main']['App']['GetConstants']();
+}
+
+export function GetDatabasePath() {
+  return window['go']['main']['App']['GetDatabasePath']();
+}
+
+export function GetHistoryState() {
+  return window['go']['main']['App']['GetHistoryState']();
+}
+
+export function GetLoadError() {
+  return window['go']['main']['App']['GetLoadError']();
+}
+
+export function GetLoadReport() {
+  return window['go']['main']['App']['GetLoadReport']();
+}
+
 export function GetProducts() {
   return window['go']['main']['App']['GetProducts']();
 }
 
+export function GetRecentDatabases() {
+  return window['go']['main']['App']['GetRecentDatabases']();
+}
+
+export function GetSettings() {
+  return window['go']['main']['App']['GetSettings']();
+}
+
+export function GetSettingsError() {
+  return window['go']['main']['App']['GetSettingsError']();
+}
+
+export function GetTimeMismatches() {
+  return window['go']['main']['App']['GetTimeMismatches']();
+}
+
+export function ImportQuarantined(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ImportQuarantined'](arg1, arg2, arg3);
+}
+
+export function IsReadOnly() {
+  return window['go']['main']['App']['IsReadOnly']();
+}
+
+export function ListBackups() {
+  return window['go']['main']['App']['ListBackups']();
+}
+
+export function NewDatabase() {
+  return window['go']['main']['App']['NewDatabase']();
+}
+
+export function OpenDatabase() {
+  return window['go']['main']['App']['OpenDatabase']();
+}
+
+export function Redo() {
+  return window['go']['main']['App']['Redo']();
+}
+
+export function ReloadDatabase() {
+  return window['go']['main']['App']['ReloadDatabase']();
+}
+
+export function RestoreBackup(arg1) {
+  return window['go']['main']['App']['RestoreBackup'](arg1);
+}
+
 export function SearchProducts(arg1) {
   return window['go']['main']['App']['SearchProducts'](arg1);
 }
 
-export function UpdateProduct(arg1, arg2, arg3) {
-  return window['go']['main']['App']['UpdateProduct'](arg1, arg2, arg3);
+export function SetShiftHours(arg1) {
+  return window['go']['main']['App']['SetShiftHours'](arg1);
+}
+
+export function SwitchDatabase(arg1) {
+  return window['go']['main']['App']['SwitchDatabase'](arg1);
+}
+
+export function Undo() {
+  return window['go']['main']['App']['Undo']();
+}
+
+export function UpdateConstant(arg1, arg2) {
+  return window['go']['main']['App']['UpdateConstant'](arg1, arg2);
+}
+
+export function UpdateProduct(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['UpdateProduct'](arg1, arg2, arg3, arg4);
+}
+
+export function UpdateSettings(arg1) {
+  return window['go']['main']['App']['UpdateSettings'](arg1);
+}
+
+export function ValidateFormula(arg1) {
+  return window['go']['main']['App']['ValidateFormula'](arg1);
 }
@@ -1,10 +1,248 @@
+export namespace config {
+	
+	export class API {
+	    enabled: boolean;
+	    address: string;
+	    token: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new API(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.address = source["address"];
+	        this.token = source["token"];
+	    }
+	}
+	
+	export class Backup {
+	    keepLast: number;
+	    keepDays: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Backup(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.keepLast = source["keepLast"];
+	        this.keepDays = source["keepDays"];
+	    }
+	}
+	
+	export class Excel {
+	    sheet: string;
+	    columnAliases: Record<string, Array<string>>;
+	
+	    static createFrom(source: any = {}) {
+	        return new Excel(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sheet = source["sheet"];
+	        this.columnAliases = source["columnAliases"];
+	    }
+	}
+	
+	export class Settings {
+	    databasePath: string;
+	    recentDatabases: string[];
+	    readOnly: boolean;
+	    shiftHours: number;
+	    window: Window;
+	    view: View;
+	    api: API;
+	    excel: Excel;
+	    backup: Backup;
+	
+	    static createFrom(source: any = {}) {
+	        return new Settings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.databasePath = source["databasePath"];
+	        this.recentDatabases = source["recentDatabases"];
+	        this.readOnly = source["readOnly"];
+	        this.shiftHours = source["shiftHours"];
+	        this.window = this.convertValues(source["window"], Window);
+	        this.view = this.convertValues(source["view"], View);
+	        this.api = this.convertValues(source["api"], API);
+	        this.excel = this.convertValues(source["excel"], Excel);
+	        this.backup = this.convertValues(source["backup"], Backup);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class View {
+	    sortDirection: string;
+	    searchQuery: string;
+	    filterBySelected: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new View(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sortDirection = source["sortDirection"];
+	        this.searchQuery = source["searchQuery"];
+	        this.filterBySelected = source["filterBySelected"];
+	    }
+	}
+	
+	export class Window {
+	    width: number;
+	    height: number;
+	    x: number;
+	    y: number;
+	    maximised: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Window(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.width = source["width"];
+	        this.height = source["height"];
+	        this.x = source["x"];
+	        this.y = source["y"];
+	        this.maximised = source["maximised"];
+	    }
+	}
+
+}
+
+export namespace history {
+	
+	export class State {
+	    canUndo: boolean;
+	    canRedo: boolean;
+	    undoLabel: string;
+	    redoLabel: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new State(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.canUndo = source["canUndo"];
+	        this.canRedo = source["canRedo"];
+	        this.undoLabel = source["undoLabel"];
+	        this.redoLabel = source["redoLabel"];
+	    }
+	}
+
+}
+
+export namespace main {
+	
+	export class FormulaValidation {
+	    valid: boolean;
+	    processingTime: number;
+	    error?: utils.FormulaError;
+	
+	    static createFrom(source: any = {}) {
+	        return new FormulaValidation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.valid = source["valid"];
+	        this.processingTime = source["processingTime"];
+	        this.error = this.convertValues(source["error"], utils.FormulaError);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class TimeMismatch {
+	    id: number;
+	    name: string;
+	    timeCalculation: string;
+	    storedTime: number;
+	    calculatedTime: number;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TimeMismatch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.timeCalculation = source["timeCalculation"];
+	        this.storedTime = source["storedTime"];
+	        this.calculatedTime = source["calculatedTime"];
+	        this.error = source["error"];
+	    }
+	}
+
+}
+
 export namespace models {
 	
+	export class Constant {
+	    name: string;
+	    value: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Constant(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.value = source["value"];
+	    }
+	}
+	
 	export class Product {
 	    id: number;
 	    name: string;
 	    processingTime: number;
 	    timeCalculation: string;
+	    version: number;
+	    updatedAt: any;
 	
 	    static createFrom(source: any = {}) {
 	        return new Product(source);
@@ -16,6 +254,163 @@ export namespace models {
 	        this.name = source["name"];
 	        this.processingTime = source["processingTime"];
 	        this.timeCalculation = source["timeCalculation"];
+	        this.version = source["version"];
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace storage {
+	
+	export class Backup {
+	    id: string;
+	    createdAt: any;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Backup(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.size = source["size"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class LoadIssue {
+	    sheet: string;
+	    row: number;
+	    column: string;
+	    value: string;
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LoadIssue(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sheet = source["sheet"];
+	        this.row = source["row"];
+	        this.column = source["column"];
+	        this.value = source["value"];
+	        this.reason = source["reason"];
+	    }
+	}
+	
+	export class LoadReport {
+	    issues: LoadIssue[];
+	    quarantine: QuarantinedRow[];
+	
+	    static createFrom(source: any = {}) {
+	        return new LoadReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.issues = this.convertValues(source["issues"], LoadIssue);
+	        this.quarantine = this.convertValues(source["quarantine"], QuarantinedRow);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class QuarantinedRow {
+	    key: string;
+	    sheet: string;
+	    row: number;
+	    cells: string[];
+	    extraHeaders: string[];
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new QuarantinedRow(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.key = source["key"];
+	        this.sheet = source["sheet"];
+	        this.row = source["row"];
+	        this.cells = source["cells"];
+	        this.extraHeaders = source["extraHeaders"];
+	        this.reason = source["reason"];
+	    }
+	}
+
+}
+
+export namespace utils {
+	
+	export class FormulaError {
+	    position: number;
+	    token: string;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FormulaError(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.position = source["position"];
+	        this.token = source["token"];
+	        this.message = source["message"];
 	    }
 	}
 
@@ -2,13 +2,19 @@ package main
 
 import (
 	"embed"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
 	"github.com/wailsapp/wails/v2/pkg/options/windows"
 
+	"github.com/Mr-Cheen1/go-reg-wails/backend/config"
 	"github.com/Mr-Cheen1/go-reg-wails/backend/storage"
 )
 
@@ -16,26 +22,46 @@ import (
 var assets embed.FS
 
 func main() {
-	// Создаем хранилище
-	excelStorage := storage.NewExcelStorage()
-	defer excelStorage.Close()
+	// Команды без окна: go-reg-wails cli <команда>
+	if len(os.Args) > 1 && os.Args[1] == "cli" {
+		_, settings, _ := loadSettings()
+		os.Exit(runCLI(os.Args[2:], settings, os.Stdout, os.Stderr))
+	}
+
+	dbPath := flag.String("db", "", "файл базы данных: .xlsx для Excel, .db или .sqlite для SQLite;\nпо умолчанию последняя открытая база или database.xlsx")
+	readOnly := flag.Bool("readonly", false, "режим просмотра: изменения данных запрещены")
+	flag.Parse()
+
+	settingsPath, settings, settingsErr := loadSettings()
+	settings.UseDatabase(resolveDatabasePath(*dbPath, settings))
+	// Режим просмотра включается флагом запуска или настройкой
+	*readOnly = *readOnly || settings.ReadOnly
+
+	// Создаем хранилище по типу файла, его закрывает app.Shutdown
+	dataStorage := storage.NewForFile(settings.DatabasePath, *readOnly, excelOptions(settings))
 
 	// Создаем экземпляр приложения
-	app := NewApp(excelStorage)
+	app := NewApp(dataStorage).WithReadOnly(*readOnly).WithSettings(settingsPath, settings).WithSettingsError(settingsErr)
+	if err := app.saveSettings(); err != nil {
+		log.Printf("Ошибка сохранения настроек: %v\n", err)
+	}
 
 	// Создаем приложение Wails
 	err := wails.Run(&options.App{
 		Title:     "Редактор базы данных",
-		Width:     800,
-		Height:    650,
-		MinWidth:  700,
-		MinHeight: 500,
+		Width:     settings.Window.Width,
+		Height:    settings.Window.Height,
+		MinWidth:  config.MinWindowWidth,
+		MinHeight: config.MinWindowHeight,
 		AssetServer: &assetserver.Options{
 			Assets: assets,
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.Startup,
 		OnDomReady:       app.OnDomReady,
+		OnBeforeClose:    app.BeforeClose,
+		OnShutdown:       app.Shutdown,
+		ErrorFormatter:   formatError,
 		Bind: []interface{}{
 			app,
 		},
@@ -49,3 +75,49 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// loadSettings загружает настройки из каталога настроек пользователя.
+// Если файл настроек недоступен, возвращаются настройки по умолчанию
+// и пустой путь: изменения настроек не сохраняются, чтобы не затереть файл.
+// Поврежденный файл переименовывается, а ошибка возвращается, чтобы сообщить
+// пользователю, что настройки сброшены.
+func loadSettings() (string, config.Settings, error) {
+	settingsPath, err := config.DefaultPath()
+	if err != nil {
+		log.Printf("Настройки не будут сохранены: %v\n", err)
+		return "", config.Default(), nil
+	}
+	settings, err := config.Load(settingsPath)
+	if errors.Is(err, config.ErrInvalid) {
+		bad, moveErr := config.MoveAside(settingsPath)
+		if moveErr != nil {
+			log.Printf("Настройки не будут сохранены: %v\n", errors.Join(err, moveErr))
+			return "", settings, err
+		}
+		err = fmt.Errorf("%w; он переименован в %s, используются настройки по умолчанию", err, bad)
+		log.Println(err)
+		return settingsPath, settings, err
+	}
+	if err != nil {
+		log.Printf("Настройки не будут сохранены: %v\n", err)
+		return "", settings, err
+	}
+	return settingsPath, settings, nil
+}
+
+// resolveDatabasePath возвращает полный путь к базе данных: указанной флагом
+// запуска, последней открытой или database.xlsx в рабочей директории
+func resolveDatabasePath(dbFlag string, settings config.Settings) string {
+	databasePath := settings.DatabasePath
+	switch {
+	case dbFlag != "":
+		databasePath = dbFlag
+	case databasePath == "":
+		databasePath = "database.xlsx"
+	}
+	// Полный путь не зависит от каталога запуска
+	if absolute, err := filepath.Abs(databasePath); err == nil {
+		databasePath = absolute
+	}
+	return databasePath
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/storage"
+)
+
+// maxRequestBody ограничение размера тела запроса к API
+const maxRequestBody = 1 << 20
+
+// errNotFound возвращается API, если продукта с указанным ID нет
+var errNotFound = errors.New("продукт не найден")
+
+// errBadRequest возвращается API при некорректном запросе
+var errBadRequest = errors.New("некорректный запрос")
+
+// APIServer HTTP API для запросов к реестру из других программ.
+// Изменения проходят через те же проверки, что и в окне приложения.
+type APIServer struct {
+	app      *App
+	token    string
+	server   *http.Server
+	listener net.Listener
+}
+
+// productRequest тело запроса на добавление или изменение продукта.
+// При изменении незаданные поля сохраняют прежние значения,
+// а без версии продукт изменяется независимо от того, кто изменял его последним.
+type productRequest struct {
+	Name            *string `json:"name"`
+	TimeCalculation *string `json:"timeCalculation"`
+	Version         int     `json:"version"`
+}
+
+// errorResponse тело ответа с ошибкой
+type errorResponse struct {
+	Error string `json:"error"`
+	// Current текущее состояние продукта при конфликте версий
+	Current *models.Product `json:"current,omitempty"`
+}
+
+// NewAPIServer создает HTTP API. Запросы без ключа доступа token отклоняются.
+func NewAPIServer(app *App, token string) *APIServer {
+	return &APIServer{app: app, token: token}
+}
+
+// Handler возвращает обработчик запросов API
+func (s *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /products", s.listProducts)
+	mux.HandleFunc("POST /products", s.addProduct)
+	mux.HandleFunc("GET /products/search", s.searchProducts)
+	mux.HandleFunc("GET /products/{id}", s.getProduct)
+	mux.HandleFunc("PUT /products/{id}", s.updateProduct)
+	mux.HandleFunc("DELETE /products/{id}", s.deleteProduct)
+	return s.authorize(mux)
+}
+
+// Start начинает принимать запросы на адресе addr.
+// Ошибка занятого порта возвращается сразу, а не из фоновой горутины.
+func (s *APIServer) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("ошибка при запуске HTTP API на %s: %w", addr, err)
+	}
+
+	s.listener = listener
+	s.server = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Ошибка HTTP API: %v\n", err)
+		}
+	}()
+	log.Printf("HTTP API доступен на http://%s\n", s.Addr())
+	return nil
+}
+
+// Addr возвращает адрес, на котором принимаются запросы, или пустую строку до запуска
+func (s *APIServer) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown останавливает прием запросов, дожидаясь завершения начатых
+func (s *APIServer) Shutdown(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Shutdown(ctx)
+}
+
+// authorize пропускает только запросы с ключом доступа в заголовке Authorization: Bearer
+func (s *APIServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-reg-wails"`)
+			writeError(w, http.StatusUnauthorized, errors.New("неверный ключ доступа"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listProducts возвращает все продукты
+func (s *APIServer) listProducts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, nonNil(s.app.GetProducts()))
+}
+
+// searchProducts возвращает продукты, наименование которых содержит параметр q
+func (s *APIServer) searchProducts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, nonNil(s.app.SearchProducts(r.URL.Query().Get("q"))))
+}
+
+// getProduct возвращает продукт по ID
+func (s *APIServer) getProduct(w http.ResponseWriter, r *http.Request) {
+	product, err := s.pathProduct(r)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, product)
+}
+
+// addProduct добавляет продукт и возвращает его с присвоенным ID
+func (s *APIServer) addProduct(w http.ResponseWriter, r *http.Request) {
+	request, err := readProductRequest(w, r)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	if request.Name == nil || strings.TrimSpace(*request.Name) == "" || request.TimeCalculation == nil {
+		writeAppError(w, fmt.Errorf("%w: нужны наименование и формула", errBadRequest))
+		return
+	}
+
+	product, err := s.app.addProduct(strings.TrimSpace(*request.Name), *request.TimeCalculation)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	s.notifyChanged()
+	w.Header().Set("Location", fmt.Sprintf("/products/%d", product.ID))
+	writeJSON(w, http.StatusCreated, product)
+}
+
+// updateProduct изменяет наименование или формулу продукта и возвращает его
+func (s *APIServer) updateProduct(w http.ResponseWriter, r *http.Request) {
+	product, err := s.pathProduct(r)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	request, err := readProductRequest(w, r)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	name, timeCalculation, version := product.Name, product.TimeCalculation, product.Version
+	if request.Name != nil {
+		name = strings.TrimSpace(*request.Name)
+		if name == "" {
+			writeAppError(w, fmt.Errorf("%w: пустое наименование", errBadRequest))
+			return
+		}
+	}
+	if request.TimeCalculation != nil {
+		timeCalculation = *request.TimeCalculation
+	}
+	if request.Version != 0 {
+		version = request.Version
+	}
+
+	if err := s.app.UpdateProduct(product.ID, version, name, timeCalculation); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	s.notifyChanged()
+	updated, err := findProduct(s.app, product.ID)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// deleteProduct удаляет продукт. Параметр version работает так же, как при изменении.
+func (s *APIServer) deleteProduct(w http.ResponseWriter, r *http.Request) {
+	product, err := s.pathProduct(r)
+	if err != nil {
+		writeAppError(w, err)
+		return
+	}
+
+	version := product.Version
+	if value := r.URL.Query().Get("version"); value != "" {
+		if version, err = strconv.Atoi(value); err != nil {
+			writeAppError(w, fmt.Errorf("%w: некорректная версия %q", errBadRequest, value))
+			return
+		}
+	}
+
+	if err := s.app.DeleteProduct(product.ID, version); err != nil {
+		writeAppError(w, err)
+		return
+	}
+	s.notifyChanged()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyChanged сообщает окну приложения об изменении реестра через API,
+// чтобы таблица обновилась
+func (s *APIServer) notifyChanged() {
+	s.app.emit(s.app.ctx, EventProductsChanged)
+}
+
+// pathProduct возвращает продукт, ID которого указан в пути запроса
+func (s *APIServer) pathProduct(r *http.Request) (models.Product, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return models.Product{}, fmt.Errorf("%w: некорректный ID %q", errBadRequest, r.PathValue("id"))
+	}
+	product, err := findProduct(s.app, id)
+	if err != nil {
+		return models.Product{}, fmt.Errorf("%w: #%d", errNotFound, id)
+	}
+	return product, nil
+}
+
+// readProductRequest читает тело запроса на добавление или изменение продукта
+func readProductRequest(w http.ResponseWriter, r *http.Request) (productRequest, error) {
+	var request productRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return request, fmt.Errorf("%w: %w", errBadRequest, err)
+	}
+	return request, nil
+}
+
+// writeAppError отвечает ошибкой с кодом, соответствующим ее причине
+func writeAppError(w http.ResponseWriter, err error) {
+	var conflict *models.ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error(), Current: conflict.Current})
+	case errors.Is(err, storage.ErrConflict):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, errNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, errBadRequest):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, ErrReadOnly):
+		writeError(w, http.StatusForbidden, err)
+	case errors.Is(err, ErrInvalidFormula), errors.Is(err, ErrReferenced):
+		writeError(w, http.StatusUnprocessableEntity, err)
+	default:
+		log.Printf("Ошибка HTTP API: %v\n", err)
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// writeError отвечает ошибкой в формате JSON
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// writeJSON отвечает значением в формате JSON
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Ошибка записи ответа HTTP API: %v\n", err)
+	}
+}
+
+// nonNil заменяет отсутствующий список пустым, чтобы в JSON был [] вместо null
+func nonNil(products []models.Product) []models.Product {
+	if products == nil {
+		return []models.Product{}
+	}
+	return products
+}
+
+// newAPIToken создает случайный ключ доступа к API
+func newAPIToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("ошибка при создании ключа доступа: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Mr-Cheen1/go-reg-wails/backend/config"
+	"github.com/Mr-Cheen1/go-reg-wails/backend/models"
+)
+
+const testToken = "test-token"
+
+// apiRequest выполняет запрос к API и возвращает код ответа и тело
+func apiRequest(t *testing.T, handler http.Handler, method, target, body, token string) (int, string) {
+	t.Helper()
+	var request *http.Request
+	if body == "" {
+		request = httptest.NewRequest(method, target, nil)
+	} else {
+		request = httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestAPIServer(t *testing.T) {
+	app := NewApp(NewMockStorage(models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 1.5, TimeCalculation: "1.5", Version: 1},
+		{ID: 2, Name: "Сборка", ProcessingTime: 3, TimeCalculation: "#1*2", Version: 1},
+	}))
+	if err := app.products.Write(app.reload); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	var events []string
+	app.emit = func(_ context.Context, name string, _ ...interface{}) {
+		events = append(events, name)
+	}
+	handler := NewAPIServer(app, testToken).Handler()
+
+	// Шаги выполняются по порядку над одним реестром
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		token    string
+		wantCode int
+		wantBody string // часть ожидаемого тела ответа
+		// wantEvent окно приложения получает событие об изменении реестра
+		wantEvent bool
+	}{
+		{name: "без ключа", method: "GET", target: "/products", wantCode: http.StatusUnauthorized},
+		{name: "неверный ключ", method: "GET", target: "/products", token: "wrong", wantCode: http.StatusUnauthorized},
+		{name: "список", method: "GET", target: "/products", token: testToken, wantCode: http.StatusOK, wantBody: `"name":"Сборка"`},
+		{name: "поиск", method: "GET", target: "/products/search?q=%D0%B2%D0%B0%D0%BB", token: testToken, wantCode: http.StatusOK, wantBody: `[{"id":1,`},
+		{name: "пустой поиск", method: "GET", target: "/products/search?q=xyz", token: testToken, wantCode: http.StatusOK, wantBody: "[]"},
+		{name: "продукт", method: "GET", target: "/products/2", token: testToken, wantCode: http.StatusOK, wantBody: `"processingTime":3`},
+		{name: "несуществующий продукт", method: "GET", target: "/products/99", token: testToken, wantCode: http.StatusNotFound},
+		{name: "некорректный ID", method: "GET", target: "/products/abc", token: testToken, wantCode: http.StatusBadRequest},
+		{
+			name: "добавление", method: "POST", target: "/products", token: testToken,
+			body:     `{"name": "Корпус", "timeCalculation": "2 + 30m"}`,
+			wantCode: http.StatusCreated, wantBody: `"id":3,"name":"Корпус","processingTime":2.5`, wantEvent: true,
+		},
+		{
+			name: "добавление без формулы", method: "POST", target: "/products", token: testToken,
+			body: `{"name": "Корпус"}`, wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "добавление с ошибкой формулы", method: "POST", target: "/products", token: testToken,
+			body: `{"name": "Корпус", "timeCalculation": "2+"}`, wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "неизвестное поле", method: "POST", target: "/products", token: testToken,
+			body: `{"name": "Корпус", "time": "2"}`, wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "изменение формулы с пересчетом ссылок", method: "PUT", target: "/products/1", token: testToken,
+			body: `{"timeCalculation": "2"}`, wantCode: http.StatusOK, wantBody: `"name":"Вал","processingTime":2,"timeCalculation":"2","version":2`,
+			wantEvent: true,
+		},
+		{name: "пересчитанная сборка", method: "GET", target: "/products/2", token: testToken, wantCode: http.StatusOK, wantBody: `"processingTime":4`},
+		{
+			name: "изменение устаревшей версии", method: "PUT", target: "/products/1", token: testToken,
+			body: `{"name": "Вал 2", "version": 1}`, wantCode: http.StatusConflict, wantBody: `"current":{"id":1`,
+		},
+		{name: "удаление продукта со ссылками", method: "DELETE", target: "/products/1", token: testToken, wantCode: http.StatusUnprocessableEntity},
+		{name: "удаление устаревшей версии", method: "DELETE", target: "/products/2?version=5", token: testToken, wantCode: http.StatusConflict},
+		{name: "удаление", method: "DELETE", target: "/products/2?version=1", token: testToken, wantCode: http.StatusNoContent, wantEvent: true},
+		{name: "удаленный продукт", method: "GET", target: "/products/2", token: testToken, wantCode: http.StatusNotFound},
+		{name: "неподдерживаемый метод", method: "PATCH", target: "/products/1", token: testToken, wantCode: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events = nil
+			code, body := apiRequest(t, handler, tt.method, tt.target, tt.body, tt.token)
+			if code != tt.wantCode {
+				t.Fatalf("%s %s = %d %s, want %d", tt.method, tt.target, code, body, tt.wantCode)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("%s %s тело = %s, want содержит %s", tt.method, tt.target, body, tt.wantBody)
+			}
+			if got := len(events) == 1 && events[0] == EventProductsChanged; got != tt.wantEvent {
+				t.Errorf("%s %s события = %v, want событие: %v", tt.method, tt.target, events, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestAPIServer_ReadOnly(t *testing.T) {
+	app := NewApp(NewMockStorage(models.Products{
+		{ID: 1, Name: "Вал", ProcessingTime: 1, TimeCalculation: "1", Version: 1},
+	})).WithReadOnly(true)
+	if err := app.products.Write(app.reload); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	handler := NewAPIServer(app, testToken).Handler()
+
+	if code, body := apiRequest(t, handler, "GET", "/products/1", "", testToken); code != http.StatusOK {
+		t.Errorf("GET в режиме просмотра = %d %s, want 200", code, body)
+	}
+	if code, body := apiRequest(t, handler, "PUT", "/products/1", `{"name": "Вал 2"}`, testToken); code != http.StatusForbidden {
+		t.Errorf("PUT в режиме просмотра = %d %s, want 403", code, body)
+	}
+}
+
+func TestApp_StartAPI(t *testing.T) {
+	settings := config.Default()
+	settings.API = config.API{Enabled: true, Address: "127.0.0.1:0"}
+	app := NewApp(NewMockStorage(nil)).WithSettings("", settings)
+
+	if err := app.startAPI(); err != nil {
+		t.Fatalf("startAPI() error = %v", err)
+	}
+	defer app.api.Shutdown(context.Background())
+
+	// Ключ доступа создается, если не задан
+	token := app.GetSettings().API.Token
+	if len(token) < 32 {
+		t.Fatalf("API.Token = %q, want случайный ключ", token)
+	}
+
+	request, err := http.NewRequest("GET", "http://"+app.api.Addr()+"/products", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("GET /products error = %v", err)
+	}
+	defer response.Body.Close()
+	var products []models.Product
+	if err := json.NewDecoder(response.Body).Decode(&products); err != nil || response.StatusCode != http.StatusOK || len(products) != 0 {
+		t.Errorf("GET /products = %d %v %v, want 200 и пустой список", response.StatusCode, products, err)
+	}
+
+	// Выключенный API не запускается
+	disabled := NewApp(NewMockStorage(nil))
+	if err := disabled.startAPI(); err != nil || disabled.api != nil {
+		t.Errorf("startAPI() без настройки = %v, api = %v", err, disabled.api)
+	}
+}